@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/tmicheletto/zen/internal/file"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+// Configuration keys, each of which can be set in a config file, with the
+// environment variable named by ENV_PREFIX and the upper cased key, e.g.
+// ZEN_DATA_DIR, or with the flag named by the key with dashes, e.g.
+// --data-dir. Flags take precedence over environment variables, which take
+// precedence over config files.
+const (
+	DATA_DIR_KEY      = "data_dir"
+	SCHEMA_FILE_KEY   = "schema_file"
+	INDEX_DIR_KEY     = "index_dir"
+	PERSIST_INDEX_KEY = "persist_index"
+)
+
+// FILE_KEY_SUFFIX follows the lower cased name of a type in the key of its
+// data file, e.g. tickets_file. The file keys of the types of the default
+// schema have flags of their own.
+const (
+	FILE_KEY_SUFFIX        = "_file"
+	USERS_FILE_KEY         = "users" + FILE_KEY_SUFFIX
+	ORGANIZATIONS_FILE_KEY = "organizations" + FILE_KEY_SUFFIX
+	TICKETS_FILE_KEY       = "tickets" + FILE_KEY_SUFFIX
+)
+
+const ENV_PREFIX = "ZEN"
+
+// INDEX_CACHE_DIR is the directory of the persistent indexes in the user's
+// cache directory, e.g. ~/.cache/zen, when none is given with --index-dir.
+const INDEX_CACHE_DIR = "zen"
+
+// HOME_CONFIG_FILE and LOCAL_CONFIG_FILE are the config files read when none
+// is given with --config, the latter in the working directory. Both are read
+// when they exist, the local one taking precedence.
+const (
+	HOME_CONFIG_FILE  = ".zen.yaml"
+	LOCAL_CONFIG_FILE = "zen.yaml"
+)
+
+var configFile string
+
+// initConfig reads the config files and environment variables.
+func initConfig() error {
+	viper.SetEnvPrefix(ENV_PREFIX)
+	viper.AutomaticEnv()
+
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("cannot read config file %s: %v", configFile, err)
+		}
+		return nil
+	}
+
+	paths := make([]string, 0, 2)
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, HOME_CONFIG_FILE))
+	}
+	paths = append(paths, LOCAL_CONFIG_FILE)
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		viper.SetConfigFile(path)
+		if err := viper.MergeInConfig(); err != nil {
+			return fmt.Errorf("cannot read config file %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// loadSchema reads the schema file, returning the default schema when none is
+// given.
+func loadSchema() (*search.Schema, error) {
+	path := viper.GetString(SCHEMA_FILE_KEY)
+	if path == "" {
+		return search.DefaultSchema, nil
+	}
+	path = expandHome(path)
+	f, err := file.New().Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema file %s: %v", path, err)
+	}
+	defer f.Close()
+	schema, err := search.LoadSchema(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema file %s: %v", path, err)
+	}
+	return schema, nil
+}
+
+// dataFiles locates the data files of the types of schema. Each file is read
+// from the file given for it, from the data directory when there is none, and
+// from the default location when there is no data directory either. Files in
+// a directory are found in any of the supported formats, e.g.
+// tickets.jsonl.gz.
+func dataFiles(schema *search.Schema) search.Files {
+	files := schema.DefaultFiles()
+	if dir := viper.GetString(DATA_DIR_KEY); dir != "" {
+		files = schema.DataFiles(expandHome(dir))
+	}
+	for _, t := range schema.Types {
+		files[t.Name] = findDataFile(files[t.Name])
+		if f := viper.GetString(strings.ToLower(string(t.Name)) + FILE_KEY_SUFFIX); f != "" {
+			files[t.Name] = expandHome(f)
+		}
+	}
+	return files
+}
+
+// findDataFile returns the first file that exists with the name of the .json
+// file at path and one of the supported extensions, or path itself when
+// there is none so that it is reported as missing.
+func findDataFile(path string) string {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range search.DataFileExtensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return path
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// newService returns a search service for the configured schema and data
+// files.
+func newService() (*search.Service, error) {
+	schema, err := loadSchema()
+	if err != nil {
+		return nil, err
+	}
+	return search.NewWithSchema(file.New(), schema, dataFiles(schema)), nil
+}
+
+// initService returns a service initialised for the type named typeName, or
+// for every type when typeName is empty, which must be closed once done with.
+func initService(typeName string) (*search.Service, error) {
+	svc, err := newService()
+	if err != nil {
+		return nil, err
+	}
+	var searchType search.Type
+	if typeName != "" {
+		if searchType, err = svc.Schema().ParseType(typeName); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadData(svc, searchType); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// indexDir returns the directory of the persistent indexes, or "" when there
+// is none.
+func indexDir() string {
+	if dir := viper.GetString(INDEX_DIR_KEY); dir != "" {
+		return expandHome(dir)
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cache, INDEX_CACHE_DIR)
+}
+
+// loadData loads and indexes the data files for searchType. The index is kept
+// on disk when persist_index is true, or, unless it is false, once one has
+// been built with zen index build.
+func loadData(svc *search.Service, searchType search.Type) error {
+	if dir := indexDir(); dir != "" {
+		persist := svc.HasPersistentIndex(dir)
+		if viper.IsSet(PERSIST_INDEX_KEY) {
+			persist = viper.GetBool(PERSIST_INDEX_KEY)
+		}
+		if persist {
+			svc.PersistIndex(dir)
+		}
+	}
+	if err := svc.Init(searchType); err != nil {
+		return withDataHint(err)
+	}
+	return nil
+}
+
+// withDataHint adds a hint on setting the location of the data to errors for
+// data files that do not exist.
+func withDataHint(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%v\nset the location of the data with --data-dir or --users-file, --organizations-file and --tickets-file, "+
+			"the %s_DATA_DIR environment variable, or %s in ~/%s or ./%s", err, ENV_PREFIX, DATA_DIR_KEY, HOME_CONFIG_FILE, LOCAL_CONFIG_FILE)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+var (
+	facetsBy       string
+	facetsSize     int
+	facetsInterval string
+)
+
+// facetsCmd represents the facets command
+var facetsCmd = &cobra.Command{
+	Use:   "facets <type> [field:value...]",
+	Short: "Counts records by the values of a field",
+	Long: `Counts records by the values of a field, e.g. tickets by status or users
+by role. Date fields are counted by --interval (day, month or year).
+
+The count can be limited to the records matching all of the conditions given
+as field:value, e.g.
+
+  zen facets tickets --by priority
+  zen facets tickets --by created_at --interval year
+  zen facets tickets status:pending --by type
+
+When --size leaves values out, the times they are used are counted on a last
+row. This counts values rather than records, so for list fields such as tags
+it can exceed the number of records.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := search.FacetRequest{
+			Field:    facetsBy,
+			Size:     facetsSize,
+			Interval: search.Interval(facetsInterval),
+		}
+		if len(args) > 1 {
+			conditions := make(search.And, 0, len(args)-1)
+			for _, arg := range args[1:] {
+				c, err := parseCondition(arg)
+				if err != nil {
+					return err
+				}
+				conditions = append(conditions, c)
+			}
+			req.Query = conditions
+		}
+
+		svc, err := initService(args[0])
+		if err != nil {
+			return err
+		}
+		defer svc.Close()
+
+		if req.Field == "" {
+			req.Field, err = selectValue("Count by", svc.ListFields(), "by")
+			if err != nil {
+				return err
+			}
+		}
+
+		result, err := svc.Facet(req)
+		if err != nil {
+			return err
+		}
+		fmt.Println(renderFacet(result))
+		return nil
+	},
+}
+
+func renderFacet(result *search.FacetResult) string {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{result.Field, "Count"})
+	for _, b := range result.Buckets {
+		t.AppendRow(table.Row{b.Value, b.Count})
+	}
+	// Other counts the uses of the values left out, not records, which for
+	// list fields such as tags can exceed the number of records.
+	if result.Other > 0 {
+		t.AppendRow(table.Row{"(uses of other values)", result.Other})
+	}
+	if result.Missing > 0 {
+		t.AppendRow(table.Row{"(missing)", result.Missing})
+	}
+	t.AppendFooter(table.Row{"Records", result.Total})
+	return t.Render()
+}
+
+func init() {
+	facetsCmd.Flags().StringVarP(&facetsBy, "by", "b", "", "field to count by")
+	facetsCmd.Flags().IntVarP(&facetsSize, "size", "n", 0, "maximum number of values to show, 0 shows them all")
+	facetsCmd.Flags().StringVarP(&facetsInterval, "interval", "i", string(search.MONTH_INTERVAL), "bucket size for date fields (day, month or year)")
+	rootCmd.AddCommand(facetsCmd)
+}
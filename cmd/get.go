@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jedib0t/go-pretty/v6/list"
+	"github.com/spf13/cobra"
+)
+
+// getCmd represents the get command
+var getCmd = &cobra.Command{
+	Use:   "get <type> <id>",
+	Short: "Looks up a single record by its _id",
+	Long: `Looks up a single record by its _id and displays it together with its
+related entities, e.g.
+
+  zen get tickets 436bf9b0-1147-4c0a-8439-6f79833bff5b
+  zen get users 1`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := initService(args[0])
+		if err != nil {
+			return err
+		}
+		defer svc.Close()
+
+		record, err := svc.Get(args[1])
+		if err != nil {
+			return err
+		}
+
+		l := list.NewWriter()
+		columns := append(resultColumns(svc, LIST_OUTPUT), svc.RelationNames()...)
+		appendRecord(l, orderedRecord{columns: columns, nested: svc.RelatedFields(), values: record})
+		fmt.Println(l.Render())
+		return nil
+	},
+}
+
+// appendRecord adds the fields of record to l, and then its related records,
+// in the order the schema declares them, indenting nested records and lists
+// of records beneath their field name. The elements of lists are separated as
+// in search results.
+func appendRecord(l list.Writer, record orderedRecord) {
+	for _, k := range record.keys() {
+		switch v := record.value(k).(type) {
+		case nil:
+			l.AppendItem(fmt.Sprintf("%s: none", k))
+		case orderedRecord:
+			l.AppendItem(k)
+			l.Indent()
+			appendRecord(l, v)
+			l.UnIndent()
+		case []orderedRecord:
+			l.AppendItem(fmt.Sprintf("%s: %d", k, len(v)))
+			l.Indent()
+			for i, r := range v {
+				l.AppendItem(fmt.Sprintf("%s %d", k, i))
+				l.Indent()
+				appendRecord(l, r)
+				l.UnIndent()
+			}
+			l.UnIndent()
+		default:
+			l.AppendItem(fmt.Sprintf("%s: %s", k, formatValue(v, TABLE_LIST_SEPARATOR)))
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+}
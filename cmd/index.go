@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manages the persistent index of the data files",
+	Long: `Manages the persistent index of the data files.
+
+The data files are indexed afresh each time zen is run unless the index is kept
+on disk, which it is once it has been built with zen index build, or with
+--persist-index, persist_index in a config file or ZEN_PERSIST_INDEX. Setting
+these to false ignores an index that has been built.
+
+The index is kept under --index-dir, by default in zen in the user cache
+directory, e.g. ~/.cache/zen, and is reused until the schema or the contents of
+any data file change, when it is rebuilt.`,
+}
+
+var indexBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Builds the persistent index of the data files",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, dir, err := newIndexService()
+		if err != nil {
+			return err
+		}
+		svc.PersistIndex(dir)
+		if err := svc.Reindex(); err != nil {
+			return withDataHint(err)
+		}
+		defer svc.Close()
+		status, err := svc.IndexStatus(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("built the index of the data files in %s\n", status.Dir)
+		return nil
+	},
+}
+
+var indexStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Reports whether the persistent index is up to date",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, dir, err := newIndexService()
+		if err != nil {
+			return err
+		}
+		status, err := svc.IndexStatus(dir)
+		if err != nil {
+			return withDataHint(err)
+		}
+		fmt.Println(indexSummary(status))
+		return nil
+	},
+}
+
+var indexClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Removes the persistent indexes",
+	Long: `Removes the persistent indexes of all the data files indexed, after which the
+data files are indexed afresh each time zen is run unless --persist-index is
+set.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := indexDir()
+		if dir == "" {
+			return fmt.Errorf("no index directory, set one with --index-dir")
+		}
+		n, err := search.ClearIndexes(dir)
+		if err != nil {
+			return fmt.Errorf("cannot clear the indexes in %s: %v", dir, err)
+		}
+		switch n {
+		case 0:
+			fmt.Printf("no indexes in %s\n", dir)
+		case 1:
+			fmt.Printf("removed 1 index from %s\n", dir)
+		default:
+			fmt.Printf("removed %d indexes from %s\n", n, dir)
+		}
+		return nil
+	},
+}
+
+// newIndexService returns a service for the configured data files and the
+// directory of the persistent indexes.
+func newIndexService() (*search.Service, string, error) {
+	dir := indexDir()
+	if dir == "" {
+		return nil, "", fmt.Errorf("no index directory, set one with --index-dir")
+	}
+	svc, err := newService()
+	if err != nil {
+		return nil, "", err
+	}
+	return svc, dir, nil
+}
+
+// indexSummary describes the status of the persistent index, e.g. "index in
+// ~/.cache/zen/0f3a: out of date, tickets changed since it was built at ...".
+func indexSummary(status *search.IndexStatus) string {
+	if !status.Exists() {
+		return fmt.Sprintf("no index in %s, build one with zen index build", status.Dir)
+	}
+	built := status.Built.Format(time.RFC3339)
+	if status.Current() {
+		return fmt.Sprintf("index in %s: up to date, built at %s", status.Dir, built)
+	}
+	changed := make([]string, len(status.Changed))
+	for i, t := range status.Changed {
+		changed[i] = strings.ToLower(string(t))
+	}
+	return fmt.Sprintf("index in %s: out of date, %s changed since it was built at %s, it is rebuilt when next used",
+		status.Dir, strings.Join(changed, ", "), built)
+}
+
+func init() {
+	indexCmd.AddCommand(indexBuildCmd, indexStatusCmd, indexClearCmd)
+	rootCmd.AddCommand(indexCmd)
+}
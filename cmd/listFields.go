@@ -2,46 +2,104 @@ package cmd
 
 import (
 	"fmt"
-	"log"
+	"os"
 
 	"github.com/jedib0t/go-pretty/v6/list"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
-	"github.com/tmicheletto/zen/internal/file"
+	"github.com/spf13/viper"
 	"github.com/tmicheletto/zen/internal/search"
 )
 
+var (
+	listFieldsType   string
+	listFieldsOutput string
+	listFieldsInfer  bool
+)
+
+// fieldColumns are the columns of list-fields output other than a list.
+var fieldColumns = []string{"name", "type", "list"}
+
 // listFieldsCmd represents the listFields command
 var listFieldsCmd = &cobra.Command{
 	Use:   "list-fields",
 	Short: "Lists the available fields to search",
-	Run: func(cmd *cobra.Command, args []string) {
-		searchTypePrompt := promptui.Select{
-			Label: "What would you like to search for?",
-			Items: []string{string(search.USER_SEARCH), string(search.TICKET_SEARCH), string(search.ORGANIZATION_SEARCH)},
+	Long: `Lists the available fields to search: the fields declared in the schema,
+followed by the fields found in the data files that it does not declare.
+
+The data files are not read. The undeclared fields are those found when the
+persistent index was built, and are left out when there is none, or with
+--persist-index=false. --infer reads the data files for them instead. Should
+the data files be missing or fail to parse, the declared fields are listed
+regardless.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := parseOutput(listFieldsOutput)
+		if err != nil {
+			return err
 		}
-		_, searchType, err := searchTypePrompt.Run()
+
+		svc, err := newService()
 		if err != nil {
-			log.Fatal("Prompt failed %v\n", err)
-			return
+			return err
 		}
 
-		fs := file.New()
-		svc := search.New(fs)
-		err = svc.Init(search.Type(searchType))
+		searchType, err := resolveType(svc, listFieldsType)
 		if err != nil {
-			log.Fatal(err)
-			return
+			return err
+		}
+
+		if err := svc.SetSearchType(searchType); err != nil {
+			return err
+		}
+		// The declared fields are listed whatever the state of the data.
+		if err := loadUndeclaredFields(svc, searchType); err != nil {
+			fmt.Fprintf(os.Stderr, "listing the declared fields only: %v\n", err)
+		}
+
+		if format == LIST_OUTPUT {
+			l := list.NewWriter()
+			for _, f := range svc.ListFields() {
+				l.AppendItem(f)
+			}
+			fmt.Println(l.Render())
+			return nil
 		}
 
-		l := list.NewWriter()
-		for _, f := range svc.ListFields() {
-			l.AppendItem(f)
+		names := svc.ListFields()
+		fields := make([]map[string]interface{}, len(names))
+		for i, name := range names {
+			f, err := svc.Field(name)
+			if err != nil {
+				return err
+			}
+			fields[i] = map[string]interface{}{"name": f.Name, "type": string(f.Type), "list": f.Array}
 		}
-		fmt.Println(l.Render())
+		if format == TABLE_OUTPUT {
+			fmt.Println(renderTable(fieldColumns, fields))
+			return nil
+		}
+		return writeData(os.Stdout, format, fieldColumns, nil, fields)
 	},
 }
 
+// loadUndeclaredFields adds the fields that the schema does not declare to
+// svc, from the data files with --infer and otherwise from the manifest of the
+// persistent index when there is one.
+func loadUndeclaredFields(svc *search.Service, searchType search.Type) error {
+	if listFieldsInfer {
+		return svc.LoadFields(searchType)
+	}
+	dir := indexDir()
+	if dir == "" || viper.IsSet(PERSIST_INDEX_KEY) && !viper.GetBool(PERSIST_INDEX_KEY) {
+		return nil
+	}
+	_, err := svc.LoadIndexedFields(dir)
+	return err
+}
+
 func init() {
+	listFieldsCmd.Flags().StringVarP(&listFieldsType, "type", "t", "", "type to list fields for (users, tickets or organizations)")
+	listFieldsCmd.Flags().StringVar(&listFieldsOutput, "output", LIST_OUTPUT, outputUsage)
+	listFieldsCmd.Flags().BoolVar(&listFieldsInfer, "infer", false, "read the data files for the fields the schema does not declare")
 	rootCmd.AddCommand(listFieldsCmd)
 }
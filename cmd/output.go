@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/tmicheletto/zen/internal/search"
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats. List and table are for reading and page through results on
+// a terminal, the others are data for scripts and are written all at once.
+const (
+	LIST_OUTPUT  = "list"
+	TABLE_OUTPUT = "table"
+	JSON_OUTPUT  = "json"
+	JSONL_OUTPUT = "jsonl"
+	CSV_OUTPUT   = "csv"
+	YAML_OUTPUT  = "yaml"
+)
+
+var outputFormats = []string{LIST_OUTPUT, TABLE_OUTPUT, JSON_OUTPUT, JSONL_OUTPUT, CSV_OUTPUT, YAML_OUTPUT}
+
+// TABLE_COLUMN_WIDTH is the width at which table cells wrap.
+const TABLE_COLUMN_WIDTH = 40
+
+// TABLE_LIST_SEPARATOR separates the elements of list fields in list and table
+// output.
+const TABLE_LIST_SEPARATOR = ", "
+
+// outputUsage describes the --output flag.
+var outputUsage = fmt.Sprintf("output format (%s)", strings.Join(outputFormats, ", "))
+
+func parseOutput(format string) (string, error) {
+	for _, f := range outputFormats {
+		if strings.EqualFold(format, f) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// isDataOutput reports whether format is data for scripts rather than for
+// reading.
+func isDataOutput(format string) bool {
+	return format != LIST_OUTPUT && format != TABLE_OUTPUT
+}
+
+// resultColumns returns the columns of results in format: every searchable
+// field for CSV, including those of related entities, e.g.
+// organization.name, and otherwise the fields of the records themselves.
+func resultColumns(svc *search.Service, format string) []string {
+	fields := svc.ListFields()
+	if format == CSV_OUTPUT {
+		return fields
+	}
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !strings.Contains(f, search.RELATION_SEPARATOR) {
+			columns = append(columns, f)
+		}
+	}
+	return columns
+}
+
+// SCORE_KEY and HIGHLIGHTS_KEY hold the relevance score of a result and the
+// highlighted fragments of its fields that matched in data output.
+const (
+	SCORE_KEY      = "_score"
+	HIGHLIGHTS_KEY = "_highlights"
+)
+
+// scoredHits returns the hits of results with the score of each under
+// SCORE_KEY and, when highlight is set, the fragments of the fields that
+// matched under HIGHLIGHTS_KEY, keyed by field. It also returns the fields
+// with fragments in any hit, in name order.
+func scoredHits(results *search.Results, highlight bool) ([]map[string]interface{}, []string) {
+	found := make(map[string]bool)
+	for i, hit := range results.Hits {
+		hit[SCORE_KEY] = results.Scores[i]
+		if !highlight {
+			continue
+		}
+		highlights := make(map[string]interface{})
+		for field, fragments := range hitFragments(results.Fragments, i) {
+			values := make([]interface{}, len(fragments))
+			for j, f := range fragments {
+				values[j] = f
+			}
+			highlights[field] = values
+			found[field] = true
+		}
+		hit[HIGHLIGHTS_KEY] = highlights
+	}
+	fields := make([]string, 0, len(found))
+	for f := range found {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return results.Hits, fields
+}
+
+// scoredColumns returns the columns of results in format followed by their
+// score and, when highlight is set, their highlights: in CSV a column for
+// each of the highlighted fields, e.g. _highlights.subject. In other formats
+// the related records nested under each relation come before the score.
+func scoredColumns(svc *search.Service, format string, highlight bool, highlighted []string) []string {
+	columns := resultColumns(svc, format)
+	if format != CSV_OUTPUT {
+		columns = append(columns, svc.RelationNames()...)
+	}
+	columns = append(columns, SCORE_KEY)
+	if !highlight {
+		return columns
+	}
+	if format != CSV_OUTPUT {
+		return append(columns, HIGHLIGHTS_KEY)
+	}
+	for _, f := range highlighted {
+		columns = append(columns, HIGHLIGHTS_KEY+search.RELATION_SEPARATOR+f)
+	}
+	return columns
+}
+
+// columnValue returns the value of column in a record whose related entities
+// are nested, looking up relation fields such as organization.name in the
+// nested entity, and highlights such as _highlights.subject in the
+// highlights of the record.
+func columnValue(record map[string]interface{}, column string) interface{} {
+	if i := strings.Index(column, search.RELATION_SEPARATOR); i >= 0 {
+		related, _ := record[column[:i]].(map[string]interface{})
+		return related[column[i+len(search.RELATION_SEPARATOR):]]
+	}
+	return record[column]
+}
+
+// formatValue formats a value for display or a CSV cell, joining the
+// elements of lists with sep. Missing values are blank.
+func formatValue(v interface{}, sep string) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = fmt.Sprint(e)
+		}
+		return strings.Join(elems, sep)
+	}
+	return fmt.Sprint(v)
+}
+
+// orderedRecord is a record written with the keys in columns first, in that
+// order, followed by its other keys in name order. The records nested under a
+// key are written the same way with the keys in nested[key] first, e.g. the
+// fields of the organization of a ticket in the order the schema declares
+// them.
+type orderedRecord struct {
+	columns []string
+	nested  map[string][]string
+	values  map[string]interface{}
+}
+
+func (r orderedRecord) keys() []string {
+	keys := make([]string, 0, len(r.values))
+	isColumn := make(map[string]bool, len(r.columns))
+	for _, c := range r.columns {
+		isColumn[c] = true
+		if _, ok := r.values[c]; ok {
+			keys = append(keys, c)
+		}
+	}
+	others := make([]string, 0)
+	for k := range r.values {
+		if !isColumn[k] {
+			others = append(others, k)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
+}
+
+func (r orderedRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, k := range r.keys() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(k); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(':')
+		if err := enc.Encode(r.value(k)); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (r orderedRecord) MarshalYAML() (interface{}, error) {
+	keys := r.keys()
+	m := make(yaml.MapSlice, len(keys))
+	for i, k := range keys {
+		m[i] = yaml.MapItem{Key: k, Value: r.value(k)}
+	}
+	return m, nil
+}
+
+// value returns the value of key k, ordering the keys of the records nested
+// under it.
+func (r orderedRecord) value(k string) interface{} {
+	switch v := r.values[k].(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return nil
+		}
+		return orderedRecord{columns: r.nested[k], values: v}
+	case []map[string]interface{}:
+		ordered := make([]orderedRecord, len(v))
+		for i, related := range v {
+			ordered[i] = orderedRecord{columns: r.nested[k], values: related}
+		}
+		return ordered
+	}
+	return r.values[k]
+}
+
+// writeData writes records in a data format. Objects are written with the
+// keys in columns first, and the keys of the records nested under a key with
+// those in nested[key] first. CSV is written with a column for each of
+// columns, the elements of lists separated as in CSV data files.
+func writeData(w io.Writer, format string, columns []string, nested map[string][]string, records []map[string]interface{}) error {
+	ordered := make([]orderedRecord, len(records))
+	for i, r := range records {
+		ordered[i] = orderedRecord{columns: columns, nested: nested, values: r}
+	}
+
+	switch format {
+	case JSON_OUTPUT:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ordered)
+	case JSONL_OUTPUT:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, r := range ordered {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case YAML_OUTPUT:
+		if len(records) == 0 {
+			_, err := fmt.Fprintln(w, "[]")
+			return err
+		}
+		b, err := yaml.Marshal(ordered)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case CSV_OUTPUT:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+		row := make([]string, len(columns))
+		for _, r := range records {
+			for i, c := range columns {
+				row[i] = formatValue(columnValue(r, c), search.CSV_LIST_SEPARATOR)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("%s is not a data format", format)
+}
+
+// renderTable renders records as a table with a column for each of columns.
+func renderTable(columns []string, records []map[string]interface{}) string {
+	t := table.NewWriter()
+	header := make(table.Row, len(columns))
+	configs := make([]table.ColumnConfig, len(columns))
+	for i, c := range columns {
+		header[i] = c
+		configs[i] = table.ColumnConfig{Number: i + 1, WidthMax: TABLE_COLUMN_WIDTH}
+	}
+	t.AppendHeader(header)
+	t.SetColumnConfigs(configs)
+	for _, r := range records {
+		row := make(table.Row, len(columns))
+		for i, c := range columns {
+			row[i] = formatValue(columnValue(r, c), TABLE_LIST_SEPARATOR)
+		}
+		t.AppendRow(row)
+	}
+	return t.Render()
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+// isInteractive reports whether stdin is attached to a terminal and can
+// therefore be used to prompt for missing input.
+func isInteractive() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// highlightStyle emphasises matched terms with colours when stdout is a
+// terminal and with plain text markers when it is redirected.
+func highlightStyle() search.HighlightStyle {
+	fd := os.Stdout.Fd()
+	if isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd) {
+		return search.ANSI_HIGHLIGHT
+	}
+	return search.MARKER_HIGHLIGHT
+}
+
+func requireInteractive(flag string) error {
+	if !isInteractive() {
+		return fmt.Errorf("--%s is required when stdin is not a terminal", flag)
+	}
+	return nil
+}
+
+// selectValue prompts the user to choose one of items. flag names the command
+// line flag that supplies the value non-interactively.
+func selectValue(label string, items []string, flag string) (string, error) {
+	if err := requireInteractive(flag); err != nil {
+		return "", err
+	}
+	prompt := promptui.Select{
+		Label: label,
+		Items: items,
+	}
+	_, value, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("prompt failed: %v", err)
+	}
+	return value, nil
+}
+
+// inputValue prompts the user for free text. flag names the command line flag
+// that supplies the value non-interactively.
+func inputValue(label string, flag string) (string, error) {
+	if err := requireInteractive(flag); err != nil {
+		return "", err
+	}
+	prompt := promptui.Prompt{
+		Label: label,
+	}
+	value, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("prompt failed: %v", err)
+	}
+	return value, nil
+}
+
+// resolveType returns the search type given by --type, prompting for it when
+// the flag was not supplied.
+func resolveType(svc *search.Service, searchType string) (search.Type, error) {
+	if searchType == "" {
+		var err error
+		searchType, err = selectValue("What would you like to search for?", svc.Schema().TypeNames(), "type")
+		if err != nil {
+			return "", err
+		}
+	}
+	return svc.Schema().ParseType(searchType)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+var (
+	queryLimit   int
+	queryOffset  int
+	querySort    []string
+	queryExplain bool
+	queryOutput  string
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query <type> <query>",
+	Short: "Searches the Zendesk database with a query string",
+	Long: `Searches the Zendesk database with a single line query, e.g.
+
+  zen query tickets 'status:pending priority:(high OR urgent) -tags:Ohio created_at:>2016-05-01'
+
+A query is made up of field:value conditions, all of which must match unless
+separated by OR. Conditions are negated with a leading - or NOT and grouped
+in parentheses, and a group after a field applies the field to each value in
+it. Values containing spaces are quoted, e.g. name:"Francisca Rasmussen",
+and an empty quoted value, e.g. assignee_id:"", finds records where the field
+is empty. Numeric and date fields accept ranges such as >=2016-07-01 or
+101..110, a date without a time of day being the whole day in UTC.
+
+Use --output to show the results as a table, or to write them for scripts as
+json, jsonl, csv or yaml, as for search.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if queryLimit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		format, err := parseOutput(queryOutput)
+		if err != nil {
+			return err
+		}
+		if queryExplain && format != LIST_OUTPUT {
+			return fmt.Errorf("--explain can only be used with --output %s", LIST_OUTPUT)
+		}
+		if queryOffset < 0 {
+			return fmt.Errorf("--offset cannot be negative")
+		}
+
+		svc, err := initService(args[0])
+		if err != nil {
+			return err
+		}
+		defer svc.Close()
+
+		q, err := svc.ParseQuery(strings.Join(args[1:], " "))
+		if err != nil {
+			var parseErr *search.ParseError
+			if errors.As(err, &parseErr) {
+				return fmt.Errorf("%v\n%s", err, parseErr.Pointer())
+			}
+			return err
+		}
+
+		req := search.Request{
+			Query:     q,
+			Offset:    queryOffset,
+			Limit:     resultLimit(cmd, queryLimit, format),
+			Sort:      querySort,
+			Highlight: highlightStyle(),
+			Explain:   queryExplain,
+		}
+		return pageResults(svc, req, format)
+	},
+}
+
+func init() {
+	queryCmd.Flags().IntVarP(&queryLimit, "limit", "l", search.DEFAULT_LIMIT, "maximum number of results to show per page")
+	queryCmd.Flags().IntVarP(&queryOffset, "offset", "o", 0, "number of results to skip")
+	queryCmd.Flags().StringSliceVarP(&querySort, "sort", "s", nil, "fields to sort by, prefixed with - for descending order, e.g. -due_at,priority")
+	queryCmd.Flags().BoolVar(&queryExplain, "explain", false, "show how the score of each result was computed and the terms that were matched")
+	queryCmd.Flags().StringVar(&queryOutput, "output", LIST_OUTPUT, outputUsage)
+	rootCmd.AddCommand(queryCmd)
+}
@@ -1,21 +1,80 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "zen",
 	Short: "Zendesk search",
+	Long: `Zendesk search.
+
+The data is read from users.json, organizations.json and tickets.json in the
+data directory, ./data unless set with --data-dir. Each file can also be given
+on its own, e.g. with --tickets-file. These settings can be kept in
+~/.zen.yaml or ./zen.yaml, e.g.
+
+  data_dir: ~/zendesk/export
+  tickets_file: ~/zendesk/tickets-2021.json
+
+or set with environment variables such as ZEN_DATA_DIR and ZEN_TICKETS_FILE.`,
+	// Errors are reported by Execute so that they are printed exactly once.
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return initConfig()
+	},
+}
+
+// exitError is an error that exits with code rather than 1. An exitError
+// without err exits without printing anything, the command having already
+// reported why.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var exit *exitError
+		if errors.As(err, &exit) {
+			if exit.err != nil {
+				fmt.Println(exit.err)
+			}
+			os.Exit(exit.code)
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&configFile, "config", "", fmt.Sprintf("config file (default ~/%s and ./%s)", HOME_CONFIG_FILE, LOCAL_CONFIG_FILE))
+	flags.String("schema-file", "", "schema file declaring the types of record searched (default users, tickets and organizations)")
+	flags.String("data-dir", "", "directory holding the data files, e.g. users.json, organizations.json and tickets.json (default ./data)")
+	flags.String("users-file", "", "users data file")
+	flags.String("organizations-file", "", "organizations data file")
+	flags.String("tickets-file", "", "tickets data file")
+	flags.Bool("persist-index", false, "keep the index on disk and reuse it until the data files change")
+	flags.String("index-dir", "", fmt.Sprintf("directory of the persistent indexes (default %s in the user cache directory, e.g. ~/.cache/%s)", INDEX_CACHE_DIR, INDEX_CACHE_DIR))
+	for _, key := range []string{SCHEMA_FILE_KEY, DATA_DIR_KEY, USERS_FILE_KEY, ORGANIZATIONS_FILE_KEY, TICKETS_FILE_KEY, PERSIST_INDEX_KEY, INDEX_DIR_KEY} {
+		if err := viper.BindPFlag(key, flags.Lookup(strings.ReplaceAll(key, "_", "-"))); err != nil {
+			panic(err)
+		}
+	}
+}
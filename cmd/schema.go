@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the schema of the records searched",
+	Long: `Prints the schema declaring the types of record searched, their fields and
+how they relate to each other, as YAML. This is the default schema unless
+another is given with --schema-file, and can be used as a starting point for
+one, e.g.
+
+  zen schema > schema.yaml
+  zen search --schema-file schema.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema, err := loadSchema()
+		if err != nil {
+			return err
+		}
+		b, err := yaml.Marshal(schema)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(b))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
@@ -2,87 +2,734 @@ package cmd
 
 import (
 	"fmt"
-	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
-	"github.com/tmicheletto/zen/internal/file"
-
-	"github.com/manifoldco/promptui"
 	"github.com/tmicheletto/zen/internal/search"
 
 	"github.com/jedib0t/go-pretty/v6/list"
 	"github.com/spf13/cobra"
 )
 
+const (
+	MATCH_VALUE     = "A value"
+	MATCH_NOT_VALUE = "Anything but a value"
+	MATCH_ANY_OF    = "Any of several values"
+	MATCH_ALL_OF    = "All of several values"
+	MATCH_EMPTY     = "An empty or missing value"
+	MATCH_NOT_EMPTY = "A value that is not empty"
+
+	RUN_SEARCH    = "Run search"
+	ADD_CONDITION = "Add another condition"
+	MATCH_ALL     = "Match all conditions (AND)"
+	MATCH_ANY     = "Match any condition (OR)"
+
+	NEXT_PAGE     = "Next page"
+	PREVIOUS_PAGE = "Previous page"
+	QUIT          = "Quit"
+)
+
+var (
+	searchType      string
+	searchField     string
+	searchValue     string
+	searchEmpty     bool
+	searchLimit     int
+	searchOffset    int
+	searchSort      []string
+	searchAny       bool
+	searchNot       []string
+	searchMode      string
+	searchFuzziness int
+	searchAll       bool
+	searchExplain   bool
+	searchAnyOf     []string
+	searchAllOf     []string
+	searchOutput    string
+)
+
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
-	Use:   "search",
+	Use:   "search [field:value...]",
 	Short: "Searches the Zendesk database",
-	Run: func(cmd *cobra.Command, args []string) {
-		fs := file.New()
-		svc := search.New(fs)
+	Long: `Searches the Zendesk database.
+
+Any of --type, --field and --value that are not supplied are prompted for,
+so a fully specified search runs without a terminal, e.g.
+
+  zen search --type tickets --field status --value pending
+
+The field and value can also be given together as field:value. Numeric and
+date fields accept ranges such as >=2016-07-01, <2014-01-01 or 101..110, e.g.
+
+  zen search --type tickets due_at:>=2016-07-01
+  zen search --type organizations _id:101..110
+
+A date without a time of day is the whole day in UTC rather than at the
+offset of each record, so 2016-07-31T20:00:00 -10:00 falls on the 1st of
+August.
+
+Text and keyword fields are matched word by word unless another match mode
+is set with --mode: exact, fuzzy, prefix, wildcard or regex. Fuzzy matching
+allows words to differ by the edit distance set with --fuzziness, e.g.
+
+  zen search --type users --mode fuzzy name:"Fransisca Rasmusen"
+  zen search --type users --mode prefix name:fran
+  zen search --type tickets --mode wildcard subject:"a problem in *"
+
+Use --any-of to find records where the field has any of several values, and
+--all-of to find those where it has all of them, which for list fields such as
+tags or domain_names means each of them is in the list. Combined with --mode
+exact, each value must match a whole element of the list, e.g.
+
+  zen search --type tickets --field status --any-of open --any-of pending
+  zen search --type tickets --field tags --mode exact --all-of "American Samoa" --all-of Ohio
+
+Use --empty to find records where the field is missing or blank, e.g.
+
+  zen search --type tickets --field assignee_id --empty
+
+Several conditions can be given, all of which must match unless --any is
+set. Records matching a condition given with --not are left out, e.g.
+
+  zen search --type tickets status:open priority:high organization_id:116
+  zen search --type tickets --any priority:high priority:urgent --not status:closed
+
+When no condition is given you are prompted for one, and can then add further
+conditions before running the search.
+
+Use --all to search users, tickets and organizations at once, e.g. for an
+email address, external_id or name of unknown type. The value is matched
+against every field unless --field is given, and results are grouped by type
+with --limit results shown for each, e.g.
+
+  zen search --all nealengland@flotonic.com
+  zen search --all --field external_id --value 6970300e-f211-4c01-a538-70b4464a1d84
+
+Results are shown a page at a time, --limit sets the page size and --offset
+the number of results to skip. On a terminal you can then move between pages.
+Results are ordered by relevance unless sorted with --sort, e.g.
+
+  zen search --type tickets --field status --value open --sort=-due_at,priority
+
+Each result shows its relevance score. Use --explain to see how the score was
+computed, along with the terms searched for and the terms of the fields that
+matched, as produced by the analysis of text fields.
+
+Use --output to show the results as a table, or to write them for scripts as
+json, jsonl, csv or yaml. These formats write every result unless --limit is
+given, without prompting for pages. JSON and YAML nest the related entities
+of each record, e.g. the organization of a ticket, while CSV has a column for
+each searchable field, including those of related entities, e.g.
+
+  zen search --type tickets --output json status:pending
+  zen search --type users --output csv --limit 100 role:admin`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if searchLimit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		format, err := parseOutput(searchOutput)
+		if err != nil {
+			return err
+		}
+		if searchExplain && format != LIST_OUTPUT {
+			return fmt.Errorf("--explain can only be used with --output %s", LIST_OUTPUT)
+		}
+		if searchOffset < 0 {
+			return fmt.Errorf("--offset cannot be negative")
+		}
+		if searchEmpty && cmd.Flags().Changed("value") {
+			return fmt.Errorf("--empty cannot be combined with a search value")
+		}
+		if len(searchAnyOf) > 0 && len(searchAllOf) > 0 {
+			return fmt.Errorf("--any-of cannot be combined with --all-of")
+		}
+		if (len(searchAnyOf) > 0 || len(searchAllOf) > 0) && (searchEmpty || cmd.Flags().Changed("value")) {
+			return fmt.Errorf("--any-of and --all-of cannot be combined with --value or --empty")
+		}
+
+		var mode search.MatchMode
+		if searchMode != "" {
+			m, err := search.ParseMatchMode(searchMode)
+			if err != nil {
+				return err
+			}
+			mode = m
+		}
+		if searchFuzziness != 0 && mode != "" && mode != search.FUZZY_MODE {
+			return fmt.Errorf("--fuzziness can only be used with --mode %s", search.FUZZY_MODE)
+		}
 
-		searchTypePrompt := promptui.Select{
-			Label: "What would you like to search for?",
-			Items: []string{string(search.USER_SEARCH), string(search.TICKET_SEARCH), string(search.ORGANIZATION_SEARCH)},
+		if searchAll {
+			if format != LIST_OUTPUT {
+				return fmt.Errorf("--all results can only be shown with --output %s", LIST_OUTPUT)
+			}
+			return searchAllTypes(cmd, args, mode)
 		}
-		_, searchType, err := searchTypePrompt.Run()
+
+		svc, err := newService()
 		if err != nil {
-			log.Fatal("Prompt failed %v\n", err)
-			return
+			return err
 		}
 
-		err = svc.Init(search.Type(searchType))
+		t, err := resolveType(svc, searchType)
 		if err != nil {
-			log.Fatal(err)
-			return
+			return err
 		}
 
-		searchTermPrompt := promptui.Select{
-			Label: "Search term",
-			Items: svc.ListFields(),
+		if err := loadData(svc, t); err != nil {
+			return err
 		}
+		defer svc.Close()
 
-		_, searchTerm, err := searchTermPrompt.Run()
+		q, err := buildQuery(cmd, svc, args, mode)
 		if err != nil {
-			log.Fatal(err)
-			return
+			return err
 		}
 
-		searchValuePrompt := promptui.Prompt{
-			Label: "Search value",
+		req := search.Request{
+			Query:     q,
+			Offset:    searchOffset,
+			Limit:     resultLimit(cmd, searchLimit, format),
+			Sort:      searchSort,
+			Highlight: highlightStyle(),
+			Explain:   searchExplain,
+		}
+		return pageResults(svc, req, format)
+	},
+}
+
+// buildQuery combines the conditions given on the command line, prompting for
+// them when there are none. Values are matched in mode, or in the mode chosen
+// for each prompted condition when mode is not set.
+func buildQuery(cmd *cobra.Command, svc *search.Service, args []string, mode search.MatchMode) (search.Query, error) {
+	conditions := make([]search.Query, 0)
+	for _, arg := range args {
+		c, err := parseCondition(arg)
+		if err != nil {
+			return nil, err
 		}
-		searchValue, err := searchValuePrompt.Run()
+		conditions = append(conditions, withMode(c, mode))
+	}
+
+	hasValue := cmd.Flags().Changed("value")
+	if searchField != "" || hasValue || searchEmpty || len(searchAnyOf) > 0 || len(searchAllOf) > 0 {
+		c, err := promptCondition(svc, flagCondition(mode), hasValue)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+
+	exclusions := make([]search.Query, 0)
+	for _, arg := range searchNot {
+		c, err := parseCondition(arg)
+		if err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, search.Not{Query: withMode(c, mode)})
+	}
+
+	matchAny := searchAny
+	if len(conditions) == 0 && len(exclusions) == 0 {
+		var err error
+		if conditions, matchAny, err = promptConditions(svc, mode); err != nil {
+			return nil, err
+		}
+	}
+
+	var q search.Query
+	switch {
+	case len(conditions) == 1:
+		q = conditions[0]
+	case matchAny:
+		q = search.Or(conditions)
+	case len(conditions) > 1:
+		q = search.And(conditions)
+	}
+	if len(exclusions) == 0 {
+		return q, nil
+	}
+	if q != nil {
+		exclusions = append([]search.Query{q}, exclusions...)
+	}
+	return search.And(exclusions), nil
+}
+
+// searchAllTypes runs a search across every type, matching the value given by
+// the positional arguments against all fields, or --value against --field.
+func searchAllTypes(cmd *cobra.Command, args []string, mode search.MatchMode) error {
+	switch {
+	case searchType != "":
+		return fmt.Errorf("--all cannot be combined with --type")
+	case len(searchSort) > 0:
+		return fmt.Errorf("--all cannot be combined with --sort, results are ordered by relevance")
+	case searchOffset > 0:
+		return fmt.Errorf("--all cannot be combined with --offset")
+	case searchAny || len(searchNot) > 0:
+		return fmt.Errorf("--all takes a single search value, --any and --not cannot be used")
+	case searchField != "" && len(args) > 0:
+		return fmt.Errorf("--all takes either --field and --value or a search value, not both")
+	case cmd.Flags().Changed("value") && len(args) > 0:
+		return fmt.Errorf("--all takes the search value either with --value or as an argument, not both")
+	case searchField == "" && (searchEmpty || mode != "" || len(searchAnyOf) > 0 || len(searchAllOf) > 0):
+		return fmt.Errorf("--empty, --mode, --any-of and --all-of need a --field when used with --all")
+	}
+
+	svc, err := initService("")
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	q, err := buildGlobalQuery(cmd, args, mode)
+	if err != nil {
+		return err
+	}
+
+	results, err := svc.SearchAll(search.Request{Query: q, Limit: searchLimit, Highlight: highlightStyle(), Explain: searchExplain})
+	if err != nil {
+		return err
+	}
+	fmt.Println(renderGlobalResults(results))
+	return nil
+}
+
+// buildGlobalQuery builds the query of a search across every type: a
+// condition on --field, or otherwise the value given by --value or the
+// positional arguments matched against all fields. The value is prompted for
+// when none is given.
+func buildGlobalQuery(cmd *cobra.Command, args []string, mode search.MatchMode) (search.Query, error) {
+	if searchField != "" && (searchEmpty || len(searchAnyOf) > 0 || len(searchAllOf) > 0) {
+		return flagCondition(mode), nil
+	}
+
+	value := strings.Join(args, " ")
+	if cmd.Flags().Changed("value") {
+		value = searchValue
+	} else if value == "" {
+		var err error
+		if value, err = inputValue("Search value", "value"); err != nil {
+			return nil, err
+		}
+	}
+	if searchField != "" {
+		return withMode(search.Condition{Field: searchField, Value: value}, mode), nil
+	}
+	return search.Text{Value: value}, nil
+}
+
+// promptConditions prompts for conditions until the user chooses to run the
+// search, returning whether any rather than all of them must match.
+func promptConditions(svc *search.Service, mode search.MatchMode) ([]search.Query, bool, error) {
+	conditions := make([]search.Query, 0)
+	for {
+		c, err := promptCondition(svc, withMode(search.Condition{}, mode), false)
+		if err != nil {
+			return nil, false, err
+		}
+		conditions = append(conditions, c)
+
+		next, err := selectValue("Next", []string{RUN_SEARCH, ADD_CONDITION}, "field")
+		if err != nil {
+			return nil, false, err
+		}
+		if next == RUN_SEARCH {
+			break
+		}
+	}
+
+	if len(conditions) == 1 {
+		return conditions, false, nil
+	}
+	match, err := selectValue("Match", []string{MATCH_ALL, MATCH_ANY}, "any")
+	if err != nil {
+		return nil, false, err
+	}
+	return conditions, match == MATCH_ANY, nil
+}
+
+// flagCondition returns the condition given by --field, --value, --empty,
+// --any-of and --all-of.
+func flagCondition(mode search.MatchMode) search.Condition {
+	c := withMode(search.Condition{Field: searchField, Value: searchValue, Empty: searchEmpty}, mode)
+	if len(searchAllOf) > 0 {
+		c.Values, c.All = searchAllOf, true
+	} else if len(searchAnyOf) > 0 {
+		c.Values = searchAnyOf
+	}
+	return c
+}
+
+// promptCondition prompts for whatever the condition c lacks, which is the
+// field when it has none and the value unless hasValue is set or it already
+// has values or searches for an empty field.
+func promptCondition(svc *search.Service, c search.Condition, hasValue bool) (search.Query, error) {
+	var err error
+	if c.Field == "" {
+		c.Field, err = selectValue("Search term", svc.ListFields(), "field")
 		if err != nil {
-			log.Fatal(err)
-			return
+			return nil, err
 		}
+	}
+	field, err := svc.Field(c.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Empty || hasValue || len(c.Values) > 0 {
+		return c, nil
+	}
 
-		results, err := svc.Search(searchTerm, searchValue)
+	items := []string{MATCH_VALUE, MATCH_NOT_VALUE, MATCH_ANY_OF}
+	if field.Array {
+		items = append(items, MATCH_ALL_OF)
+	}
+	items = append(items, MATCH_EMPTY, MATCH_NOT_EMPTY)
+	match, err := selectValue("Search for", items, "value or --empty")
+	if err != nil {
+		return nil, err
+	}
+
+	switch match {
+	case MATCH_EMPTY, MATCH_NOT_EMPTY:
+		c = search.Condition{Field: c.Field, Empty: true}
+	default:
+		if c.Mode == "" {
+			if c, err = promptMode(svc, c); err != nil {
+				return nil, err
+			}
+		}
+		if match == MATCH_ANY_OF || match == MATCH_ALL_OF {
+			c.All = match == MATCH_ALL_OF
+			if c.Values, err = promptValues(); err != nil {
+				return nil, err
+			}
+			break
+		}
+		c.Value, err = inputValue("Search value", "value")
 		if err != nil {
-			log.Fatal(err)
-			return
+			return nil, err
 		}
+	}
 
-		l := list.NewWriter()
+	if match == MATCH_NOT_VALUE || match == MATCH_NOT_EMPTY {
+		return search.Not{Query: c}, nil
+	}
+	return c, nil
+}
 
-		if len(results) > 0 {
-			for i := 0; i < 10 && i < len(results); i++ {
-				result := results[i]
-				l.AppendItem(fmt.Sprintf("Result %d", i))
-				l.Indent()
-				for k, v := range result {
-					l.AppendItem(fmt.Sprintf("%s: %v", k, v))
-				}
-				l.UnIndent()
+// promptValues prompts for values until a blank one is entered.
+func promptValues() ([]string, error) {
+	values := make([]string, 0)
+	for {
+		value, err := inputValue(fmt.Sprintf("Search value %d (blank to finish)", len(values)+1), "any-of or --all-of")
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(value) == "" {
+			if len(values) == 0 {
+				continue
 			}
+			return values, nil
+		}
+		values = append(values, value)
+	}
+}
 
+// promptMode prompts for the match mode of a condition when its field can be
+// matched in more than one way, and for the edit distance of fuzzy matches.
+func promptMode(svc *search.Service, c search.Condition) (search.Condition, error) {
+	modes, err := svc.FieldMatchModes(c.Field)
+	if err != nil || len(modes) < 2 {
+		return c, err
+	}
+	names := make([]string, len(modes))
+	for i, m := range modes {
+		names[i] = string(m)
+	}
+	name, err := selectValue("Match mode", names, "mode")
+	if err != nil {
+		return c, err
+	}
+	c.Mode = search.MatchMode(name)
+
+	if c.Mode == search.FUZZY_MODE && c.Fuzziness == 0 {
+		distances := make([]string, search.MAX_FUZZINESS)
+		for i := range distances {
+			distances[i] = strconv.Itoa(i + 1)
+		}
+		distance, err := selectValue("Edit distance", distances, "fuzziness")
+		if err != nil {
+			return c, err
+		}
+		c.Fuzziness, _ = strconv.Atoi(distance)
+	}
+	return c, nil
+}
+
+// withMode sets the match mode of a condition, along with the edit distance
+// given by --fuzziness.
+func withMode(c search.Condition, mode search.MatchMode) search.Condition {
+	c.Mode = mode
+	c.Fuzziness = searchFuzziness
+	return c
+}
+
+func parseCondition(arg string) (search.Condition, error) {
+	field, value, err := splitCondition(arg)
+	if err != nil {
+		return search.Condition{}, err
+	}
+	return search.Condition{Field: field, Value: value}, nil
+}
+
+// splitCondition splits a search given as field:value.
+func splitCondition(arg string) (string, string, error) {
+	i := strings.Index(arg, ":")
+	if i < 1 {
+		return "", "", fmt.Errorf("invalid search %q, expected field:value", arg)
+	}
+	return arg[:i], arg[i+1:], nil
+}
+
+// resultLimit returns the number of results to show, which for data formats
+// is every result unless --limit is given.
+func resultLimit(cmd *cobra.Command, limit int, format string) int {
+	if isDataOutput(format) && !cmd.Flags().Changed("limit") {
+		return 0
+	}
+	return limit
+}
+
+// pageResults shows the results of req in format. Data formats are written
+// all at once, with the score and highlighted fragments of each result, while
+// lists and tables are shown a page at a time, prompting for the next page on
+// a terminal. Tables show only the fields of the results.
+func pageResults(svc *search.Service, req search.Request, format string) error {
+	if format != LIST_OUTPUT {
+		req.Nested = true
+	}
+	if isDataOutput(format) {
+		// Matched terms are marked in plain text, as terminal colours have no
+		// place in data.
+		if req.Highlight != "" {
+			req.Highlight = search.MARKER_HIGHLIGHT
+		}
+		results, err := svc.Find(req)
+		if err != nil {
+			return err
+		}
+		records, highlighted := scoredHits(results, req.Highlight != "")
+		return writeData(os.Stdout, format, scoredColumns(svc, format, req.Highlight != "", highlighted), svc.RelatedFields(), records)
+	}
+	if format == TABLE_OUTPUT {
+		req.Highlight = ""
+	}
+
+	for {
+		results, err := svc.Find(req)
+		if err != nil {
+			return err
+		}
+		if format == TABLE_OUTPUT {
+			fmt.Println(renderResultsTable(svc, results))
 		} else {
-			l.AppendItem("No results found")
+			fmt.Println(renderResults(results))
 		}
-		fmt.Println(l.Render())
-	},
+
+		var items []string
+		if results.Offset+len(results.Hits) < int(results.Total) {
+			items = append(items, NEXT_PAGE)
+		}
+		if results.Offset > 0 {
+			items = append(items, PREVIOUS_PAGE)
+		}
+		if len(items) == 0 || !isInteractive() {
+			return nil
+		}
+		items = append(items, QUIT)
+
+		choice, err := selectValue("Page", items, "offset")
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case NEXT_PAGE:
+			req.Offset = results.Offset + req.Limit
+		case PREVIOUS_PAGE:
+			req.Offset = results.Offset - req.Limit
+			if req.Offset < 0 {
+				req.Offset = 0
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func renderResults(results *search.Results) string {
+	l := list.NewWriter()
+
+	if len(results.Hits) > 0 {
+		for i, result := range results.Hits {
+			appendResult(l, results.Offset+i+1, result, results.Scores[i], hitFragments(results.Fragments, i), hitExplanation(results.Explanations, i))
+		}
+	}
+	l.AppendItem(resultsSummary(results))
+	return l.Render()
+}
+
+func renderResultsTable(svc *search.Service, results *search.Results) string {
+	if len(results.Hits) == 0 {
+		return resultsSummary(results)
+	}
+	return renderTable(resultColumns(svc, TABLE_OUTPUT), results.Hits) + "\n" + resultsSummary(results)
+}
+
+func resultsSummary(results *search.Results) string {
+	switch {
+	case len(results.Hits) > 0:
+		return fmt.Sprintf("Showing %d-%d of %s", results.Offset+1, results.Offset+len(results.Hits), countResults(results.Total))
+	case results.Total > 0:
+		return fmt.Sprintf("No results on this page, there are %s", countResults(results.Total))
+	}
+	return "No results found"
+}
+
+// countResults describes a number of results, e.g. "1 result" or "3 results".
+func countResults(n uint64) string {
+	if n == 1 {
+		return "1 result"
+	}
+	return fmt.Sprintf("%d results", n)
+}
+
+func renderGlobalResults(results *search.GlobalResults) string {
+	l := list.NewWriter()
+
+	if results.Total == 0 {
+		l.AppendItem("No results found")
+		return l.Render()
+	}
+	for _, group := range results.Groups {
+		l.AppendItem(fmt.Sprintf("%s: %s", group.Type, countResults(group.Total)))
+		l.Indent()
+		for i, result := range group.Hits {
+			appendResult(l, i+1, result, group.Scores[i], hitFragments(group.Fragments, i), hitExplanation(group.Explanations, i))
+		}
+		if len(group.Hits) < int(group.Total) {
+			l.AppendItem(fmt.Sprintf("Showing 1-%d of %s, search --type %s to see them all", len(group.Hits), countResults(group.Total), strings.ToLower(string(group.Type))))
+		}
+		l.UnIndent()
+	}
+	l.AppendItem(fmt.Sprintf("Found %s", countResults(results.Total)))
+	return l.Render()
+}
+
+// appendResult lists the fields of a result followed by the fragments of the
+// fields that matched, with the matched terms emphasised, and its explanation
+// when there is one.
+func appendResult(l list.Writer, n int, result map[string]interface{}, score float64, fragments map[string][]string, expl *search.Explanation) {
+	l.AppendItem(fmt.Sprintf("Result %d (score %.4f)", n, score))
+	l.Indent()
+	keys := make([]string, 0, len(result))
+	for k := range result {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		l.AppendItem(fmt.Sprintf("%s: %s", k, formatValue(result[k], TABLE_LIST_SEPARATOR)))
+	}
+	if len(fragments) > 0 {
+		l.AppendItem("Matched")
+		l.Indent()
+		fields := make([]string, 0, len(fragments))
+		for k := range fragments {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		for _, k := range fields {
+			l.AppendItem(fmt.Sprintf("%s: %s", k, strings.Join(fragments[k], " … ")))
+		}
+		l.UnIndent()
+	}
+	if expl != nil {
+		appendExplanation(l, expl)
+	}
+	l.UnIndent()
+}
+
+// appendExplanation lists how the score of a result was computed, followed by
+// the terms searched for and the terms of the fields that matched.
+func appendExplanation(l list.Writer, expl *search.Explanation) {
+	l.AppendItem("Explanation")
+	l.Indent()
+	appendScoreDetail(l, expl.Score)
+	l.AppendItem("Query terms")
+	l.Indent()
+	for _, a := range expl.Query {
+		appendAnalysis(l, a)
+	}
+	l.UnIndent()
+	l.AppendItem("Matched field terms")
+	l.Indent()
+	for _, a := range expl.Fields {
+		appendAnalysis(l, a)
+	}
+	l.UnIndent()
+	l.UnIndent()
+}
+
+func appendScoreDetail(l list.Writer, detail search.ScoreDetail) {
+	l.AppendItem(fmt.Sprintf("%.4f %s", detail.Value, detail.Message))
+	if len(detail.Children) == 0 {
+		return
+	}
+	l.Indent()
+	for _, child := range detail.Children {
+		appendScoreDetail(l, child)
+	}
+	l.UnIndent()
+}
+
+func appendAnalysis(l list.Writer, a search.Analysis) {
+	l.AppendItem(fmt.Sprintf("%s %q: %s", a.Field, a.Text, strings.Join(a.Terms, ", ")))
+}
+
+func hitExplanation(explanations []*search.Explanation, i int) *search.Explanation {
+	if i < len(explanations) {
+		return explanations[i]
+	}
+	return nil
+}
+
+func hitFragments(fragments []map[string][]string, i int) map[string][]string {
+	if i < len(fragments) {
+		return fragments[i]
+	}
+	return nil
 }
 
 func init() {
+	searchCmd.Flags().StringVarP(&searchType, "type", "t", "", "type to search (users, tickets or organizations)")
+	searchCmd.Flags().StringVarP(&searchField, "field", "f", "", "field to search on")
+	searchCmd.Flags().StringVarP(&searchValue, "value", "v", "", "value to search for")
+	searchCmd.Flags().BoolVarP(&searchEmpty, "empty", "e", false, "search for records where the field is missing or blank")
+	searchCmd.Flags().StringArrayVar(&searchAnyOf, "any-of", nil, "search for records where the field has any of these values, can be repeated")
+	searchCmd.Flags().StringArrayVar(&searchAllOf, "all-of", nil, "search for records where the field has all of these values, can be repeated")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "l", search.DEFAULT_LIMIT, "maximum number of results to show per page")
+	searchCmd.Flags().IntVarP(&searchOffset, "offset", "o", 0, "number of results to skip")
+	searchCmd.Flags().BoolVar(&searchAny, "any", false, "match records meeting any rather than all of the conditions")
+	searchCmd.Flags().StringArrayVar(&searchNot, "not", nil, "leave out records matching field:value, can be repeated")
+	searchCmd.Flags().StringVarP(&searchMode, "mode", "m", "", "how values are matched: exact, match, fuzzy, prefix, wildcard or regex (default match)")
+	searchCmd.Flags().IntVar(&searchFuzziness, "fuzziness", 0, fmt.Sprintf("edit distance allowed by fuzzy matching, up to %d (default %d)", search.MAX_FUZZINESS, search.DEFAULT_FUZZINESS))
+	searchCmd.Flags().BoolVarP(&searchAll, "all", "a", false, "search users, tickets and organizations at once")
+	searchCmd.Flags().BoolVar(&searchExplain, "explain", false, "show how the score of each result was computed and the terms that were matched")
+	searchCmd.Flags().StringSliceVarP(&searchSort, "sort", "s", nil, "fields to sort by, prefixed with - for descending order, e.g. -due_at,priority")
+	searchCmd.Flags().StringVar(&searchOutput, "output", LIST_OUTPUT, outputUsage)
 	rootCmd.AddCommand(searchCmd)
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+// resetSearchFlags clears the flags of the search command set by a test.
+func resetSearchFlags() {
+	searchField, searchValue = "", ""
+	searchCmd.Flags().Lookup("value").Changed = false
+	searchCmd.Flags().Lookup("field").Changed = false
+}
+
+func TestGlobalQueryUsesValueWithoutField(t *testing.T) {
+	defer resetSearchFlags()
+	if err := searchCmd.Flags().Set("value", "nealengland@flotonic.com"); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	// Tests do not run on a terminal, so this fails if the value is prompted
+	// for.
+	q, err := buildGlobalQuery(searchCmd, nil, "")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, search.Text{Value: "nealengland@flotonic.com"}, q)
+}
+
+func TestGlobalQueryUsesValueOfField(t *testing.T) {
+	defer resetSearchFlags()
+	for name, value := range map[string]string{"field": "external_id", "value": "6970300e"} {
+		if err := searchCmd.Flags().Set(name, value); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+	}
+
+	q, err := buildGlobalQuery(searchCmd, nil, "")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, search.Condition{Field: "external_id", Value: "6970300e"}, q)
+}
+
+func TestGlobalQueryUsesArguments(t *testing.T) {
+	defer resetSearchFlags()
+
+	q, err := buildGlobalQuery(searchCmd, []string{"Burgess", "England"}, "")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, search.Text{Value: "Burgess England"}, q)
+}
+
+func TestCountResults(t *testing.T) {
+	assert.Equal(t, "1 result", countResults(1))
+	assert.Equal(t, "0 results", countResults(0))
+	assert.Equal(t, "12 results", countResults(12))
+}
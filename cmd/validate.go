@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/list"
+	"github.com/spf13/cobra"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+// Exit codes of zen validate, for use in CI.
+const (
+	VALIDATE_PROBLEMS_EXIT   = 1
+	VALIDATE_UNREADABLE_EXIT = 2
+)
+
+var validateOutput string
+
+// problemColumns are the columns of validate output other than a list.
+var problemColumns = []string{"type", "file", "record", "_id", "field", "kind", "message"}
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the data files for problems",
+	Long: `Checks the data files for problems without indexing them: organization_id,
+submitter_id and assignee_id references to records that do not exist, _ids used
+by more than one record, timestamps that cannot be parsed, unknown values of
+fields such as status, priority, type, via and role, and fields that hold
+values of the wrong type. Fields the schema does not declare, such as custom
+fields, are reported too, but only for information as they are still searched.
+
+Records are numbered from 1 in the order they appear in their file.
+
+zen validate exits with 0 when no problems are found other than undeclared
+fields, 1 when problems are found and 2 when a data file cannot be read or
+parsed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := parseOutput(validateOutput)
+		if err != nil {
+			return err
+		}
+
+		svc, err := newService()
+		if err != nil {
+			return &exitError{err: err, code: VALIDATE_UNREADABLE_EXIT}
+		}
+		result, err := svc.Validate()
+		if err != nil {
+			return &exitError{err: withDataHint(err), code: VALIDATE_UNREADABLE_EXIT}
+		}
+
+		switch format {
+		case LIST_OUTPUT:
+			if len(result.Problems) > 0 {
+				fmt.Println(renderProblems(result.Problems))
+			}
+		case TABLE_OUTPUT:
+			fmt.Println(renderTable(problemColumns, problemRecords(result.Problems)))
+		default:
+			if err := writeData(os.Stdout, format, problemColumns, nil, problemRecords(result.Problems)); err != nil {
+				return err
+			}
+		}
+
+		// The summary of data output goes to stderr to keep the data parseable.
+		summary := os.Stdout
+		if isDataOutput(format) {
+			summary = os.Stderr
+		}
+		fmt.Fprintln(summary, validationSummary(result))
+		return validationError(result)
+	},
+}
+
+// validationError returns the error zen validate exits with, which is nil
+// when the only problems found are informational.
+func validationError(result *search.ValidationResult) error {
+	if result.Failed() {
+		return &exitError{code: VALIDATE_PROBLEMS_EXIT}
+	}
+	return nil
+}
+
+// renderProblems renders problems as a list grouped by file.
+func renderProblems(problems []search.Problem) string {
+	l := list.NewWriter()
+	file := ""
+	for _, p := range problems {
+		if p.File != file || l.Length() == 0 {
+			file = p.File
+			l.UnIndent()
+			l.AppendItem(fmt.Sprintf("%s (%s)", p.File, strings.ToLower(string(p.Type))))
+			l.Indent()
+		}
+		record := fmt.Sprintf("record %d", p.Record)
+		if p.ID != "" {
+			record += fmt.Sprintf(" (_id %s)", p.ID)
+		}
+		l.AppendItem(fmt.Sprintf("%s, %s: %s: %s", record, p.Field, p.Kind, p.Message))
+	}
+	return l.Render()
+}
+
+func problemRecords(problems []search.Problem) []map[string]interface{} {
+	records := make([]map[string]interface{}, len(problems))
+	for i, p := range problems {
+		records[i] = map[string]interface{}{
+			"type":    strings.ToLower(string(p.Type)),
+			"file":    p.File,
+			"record":  p.Record,
+			"_id":     p.ID,
+			"field":   p.Field,
+			"kind":    string(p.Kind),
+			"message": p.Message,
+		}
+	}
+	return records
+}
+
+// validationSummary describes the number of records checked and problems
+// found, e.g. "checked 26 organizations, 75 users and 200 tickets: found 2
+// problems", followed by the number of informational notes when there are
+// any.
+func validationSummary(result *search.ValidationResult) string {
+	counts := make([]string, len(result.Types))
+	for i, t := range result.Types {
+		counts[i] = fmt.Sprintf("%d %s", result.Records[t], strings.ToLower(string(t)))
+	}
+	checked := counts[len(counts)-1]
+	if len(counts) > 1 {
+		checked = strings.Join(counts[:len(counts)-1], ", ") + " and " + checked
+	}
+
+	problems, undeclared := 0, 0
+	for _, p := range result.Problems {
+		if p.Kind.Informational() {
+			undeclared++
+		} else {
+			problems++
+		}
+	}
+	var found string
+	switch problems {
+	case 0:
+		found = "no problems found"
+	case 1:
+		found = "found 1 problem"
+	default:
+		found = fmt.Sprintf("found %d problems", problems)
+	}
+	switch undeclared {
+	case 0:
+		return fmt.Sprintf("checked %s: %s", checked, found)
+	case 1:
+		return fmt.Sprintf("checked %s: %s, 1 note on an undeclared field", checked, found)
+	}
+	return fmt.Sprintf("checked %s: %s, %d notes on undeclared fields", checked, found, undeclared)
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&validateOutput, "output", LIST_OUTPUT, outputUsage)
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestValidateExitsWithZeroForUndeclaredFields(t *testing.T) {
+	result := &search.ValidationResult{
+		Types:   []search.Type{search.USER_SEARCH},
+		Records: map[search.Type]int{search.USER_SEARCH: 2},
+		Problems: []search.Problem{
+			{Type: search.USER_SEARCH, Record: 1, Field: "team", Kind: search.UNDECLARED_FIELD},
+			{Type: search.USER_SEARCH, Record: 2, Field: "team", Kind: search.UNDECLARED_FIELD},
+		},
+	}
+	assert.NoError(t, validationError(result))
+	assert.Equal(t, "checked 2 users: no problems found, 2 notes on undeclared fields", validationSummary(result))
+
+	result.Problems = append(result.Problems, search.Problem{Type: search.USER_SEARCH, Record: 2, Field: "role", Kind: search.UNKNOWN_VALUE})
+	err := validationError(result)
+	if assert.Error(t, err) {
+		assert.Equal(t, VALIDATE_PROBLEMS_EXIT, err.(*exitError).code)
+	}
+	assert.Equal(t, "checked 2 users: found 1 problem, 2 notes on undeclared fields", validationSummary(result))
+}
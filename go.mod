@@ -4,9 +4,9 @@ go 1.15
 
 require (
 	github.com/blevesearch/bleve v1.0.14
-	github.com/google/uuid v1.2.0
 	github.com/jedib0t/go-pretty/v6 v6.1.0
 	github.com/manifoldco/promptui v0.8.0
+	github.com/mattn/go-isatty v0.0.4
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/pkg/errors v0.8.1
 	github.com/prometheus/common v0.4.0
@@ -14,4 +14,5 @@ require (
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/tools v0.1.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
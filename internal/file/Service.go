@@ -1,6 +1,9 @@
 package file
 
-import "io/ioutil"
+import (
+	"io"
+	"os"
+)
 
 type Service struct{}
 
@@ -8,6 +11,6 @@ func New() *Service {
 	return &Service{}
 }
 
-func (svc *Service) ReadFile(fileName string) ([]byte, error) {
-	return ioutil.ReadFile(fileName)
+func (svc *Service) Open(fileName string) (io.ReadCloser, error) {
+	return os.Open(fileName)
 }
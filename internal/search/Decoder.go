@@ -0,0 +1,221 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// CSV_LIST_SEPARATOR separates the elements of list fields such as tags in
+// CSV data files, e.g. "Ohio;Pennsylvania;American Samoa".
+const CSV_LIST_SEPARATOR = ";"
+
+// DataFileExtensions lists the extensions of the data file formats, in the
+// order they are looked for in a data directory.
+var DataFileExtensions = []string{
+	".json", ".jsonl", ".ndjson", ".csv",
+	".json.gz", ".jsonl.gz", ".ndjson.gz", ".csv.gz",
+}
+
+// RecordDecoder decodes the records of a data file one at a time, so that a
+// file can be indexed without holding all of it in memory.
+type RecordDecoder interface {
+	// Next decodes the next record into v, returning false once there are no
+	// more records.
+	Next(v interface{}) (bool, error)
+}
+
+type arrayDecoder struct {
+	dec     *json.Decoder
+	started bool
+	done    bool
+}
+
+// NewArrayDecoder returns a RecordDecoder for the elements of the JSON array
+// read from r. Only the element being decoded is held in memory. A null
+// array has no records.
+func NewArrayDecoder(r io.Reader) RecordDecoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &arrayDecoder{dec: dec}
+}
+
+func (d *arrayDecoder) Next(v interface{}) (bool, error) {
+	if d.done {
+		return false, nil
+	}
+	if !d.started {
+		d.started = true
+		t, err := d.dec.Token()
+		if err != nil {
+			return false, err
+		}
+		if t == nil {
+			return false, d.end()
+		}
+		if delim, ok := t.(json.Delim); !ok || delim != '[' {
+			return false, fmt.Errorf("expected an array of records, found %v", t)
+		}
+	}
+
+	if !d.dec.More() {
+		// Consume the closing bracket.
+		if _, err := d.dec.Token(); err != nil {
+			return false, err
+		}
+		return false, d.end()
+	}
+	if err := d.dec.Decode(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// end checks that nothing follows the array.
+func (d *arrayDecoder) end() error {
+	d.done = true
+	if _, err := d.dec.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the array of records")
+	}
+	return nil
+}
+
+// NewFileDecoder returns a RecordDecoder for the records read from r in the
+// format given by the extension of fileName:
+//
+//	.json            a JSON array of records
+//	.jsonl, .ndjson  a JSON record on each line
+//	.csv             a header row of field names followed by a record on each row
+//
+// Any of these may be gzipped with a further .gz extension, e.g.
+// tickets.jsonl.gz. Files with another extension are read as a JSON array or
+// JSON lines, whichever they hold.
+func NewFileDecoder(fileName string, r io.Reader) (RecordDecoder, error) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == ".gz" {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(fileName, filepath.Ext(fileName))))
+	}
+
+	switch ext {
+	case ".json":
+		return NewArrayDecoder(r), nil
+	case ".jsonl", ".ndjson":
+		return NewLinesDecoder(r), nil
+	case ".csv":
+		return NewCSVDecoder(r), nil
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.Peek(1)
+		if err != nil {
+			// An empty file has no records either way.
+			return NewArrayDecoder(br), nil
+		}
+		switch c[0] {
+		case ' ', '\t', '\r', '\n':
+			br.Discard(1)
+			continue
+		case '{':
+			return NewLinesDecoder(br), nil
+		}
+		return NewArrayDecoder(br), nil
+	}
+}
+
+type linesDecoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewLinesDecoder returns a RecordDecoder for JSON lines read from r, with a
+// JSON record on each line. Blank lines are skipped.
+func NewLinesDecoder(r io.Reader) RecordDecoder {
+	return &linesDecoder{r: bufio.NewReader(r)}
+}
+
+func (d *linesDecoder) Next(v interface{}) (bool, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		d.line++
+		if len(bytes.TrimSpace(line)) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			if err := dec.Decode(v); err != nil {
+				return false, fmt.Errorf("line %d: %v", d.line, err)
+			}
+			return true, nil
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+	}
+}
+
+type csvDecoder struct {
+	r      *csv.Reader
+	header []string
+}
+
+// NewCSVDecoder returns a RecordDecoder for CSV read from r. The header row
+// names the field of each column, e.g. _id or created_at. Records are decoded
+// into a map[string]interface{} holding the cells of every column as strings,
+// leaving out empty cells, which are read as missing values. The elements of
+// list fields are separated by CSV_LIST_SEPARATOR.
+func NewCSVDecoder(r io.Reader) RecordDecoder {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	return &csvDecoder{r: cr}
+}
+
+func (d *csvDecoder) Next(v interface{}) (bool, error) {
+	if d.header == nil {
+		header, err := d.r.Read()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		d.header = make([]string, len(header))
+		for i, name := range header {
+			d.header[i] = strings.TrimSpace(name)
+		}
+		// Spreadsheets often start the file with a byte order mark.
+		d.header[0] = strings.TrimPrefix(d.header[0], "\ufeff")
+	}
+
+	row, err := d.r.Read()
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	m, ok := v.(*map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("cannot decode CSV into %T", v)
+	}
+	*m = make(map[string]interface{}, len(row))
+	for i, cell := range row {
+		if cell = strings.TrimSpace(cell); cell != "" {
+			(*m)[d.header[i]] = cell
+		}
+	}
+	return true, nil
+}
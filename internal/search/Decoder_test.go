@@ -0,0 +1,435 @@
+package search_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestArrayDecoderDecodesEachRecord(t *testing.T) {
+	dec := search.NewArrayDecoder(strings.NewReader(ticketsJson))
+
+	var ids []string
+	for {
+		var ticket map[string]interface{}
+		ok, err := dec.Next(&ticket)
+		if err != nil {
+			assert.Fail(t, err.Error())
+			return
+		}
+		if !ok {
+			break
+		}
+		ids = append(ids, ticket["_id"].(string))
+	}
+	assert.Equal(t, []string{"2217c7dc-7371-4401-8738-0a8a8aedc08d", "87db32c5-76a3-4069-954c-7d59c6c21de0"}, ids)
+
+	ok, err := dec.Next(&map[string]interface{}{})
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestArrayDecoderEmptyArrays(t *testing.T) {
+	for _, input := range []string{"[]", " [ ] \n", "null"} {
+		dec := search.NewArrayDecoder(strings.NewReader(input))
+		ok, err := dec.Next(&map[string]interface{}{})
+		assert.False(t, ok, input)
+		assert.NoError(t, err, input)
+	}
+}
+
+func TestArrayDecoderInvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"not an array", `{"_id": "1"}`, "expected an array of records"},
+		{"trailing data", `[{"_id": "1"}] [`, "unexpected data after the array of records"},
+		{"truncated", `[{"_id": "1"}, {"_id": `, "unexpected EOF"},
+		{"not a record", `[1]`, "cannot unmarshal number"},
+		{"empty", ``, "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := search.NewArrayDecoder(strings.NewReader(tt.input))
+			var err error
+			for ok := true; ok && err == nil; {
+				ok, err = dec.Next(&map[string]interface{}{})
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestInitReportsUnparsableFile(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(`[{"_id": "1"}, {"_id": `), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot parse tickets data file ./data/tickets.json")
+	}
+}
+
+func TestInitReportsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		input    string
+		err      string
+	}{
+		{"number", "users.csv", "_id,organization_id\n1,2\n2,abc\n", `record 2, field organization_id: expected a number, found "abc"`},
+		{"boolean", "users.csv", "_id,active\n1,maybe\n", `record 1, field active: expected true or false, found "maybe"`},
+		{"quoted boolean", "users.json", `[{"_id": 1, "active": "true"}]`, `record 1, field active: expected true or false, found "true"`},
+		{"text", "users.json", `[{"_id": 1, "name": 2}]`, "record 1, field name: expected text, found the number 2"},
+		{"list", "users.json", `[{"_id": 1, "tags": "Ohio"}]`, `record 1, field tags: expected a list, found "Ohio"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfs := &mockFileService{}
+			mfs.On("Open", tt.fileName).Return([]byte(tt.input), nil)
+			mfs.On("Open", "organizations.json").Return([]byte(orgsJson), nil)
+			mfs.On("Open", "tickets.json").Return([]byte(ticketsJson), nil)
+
+			svc := search.NewWithFiles(mfs, search.Files{
+				search.USER_SEARCH:         tt.fileName,
+				search.ORGANIZATION_SEARCH: "organizations.json",
+				search.TICKET_SEARCH:       "tickets.json",
+			})
+			err := svc.Init(search.USER_SEARCH)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "cannot parse users data file "+tt.fileName)
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+var usersCsv = "\ufeff_id,name,active,organization_id,tags,unknown\n" +
+	"1,Burgess England,true,1,\"Riceville; Ribera;Caberfae\",x\n" +
+	"2,\"Rasmussen, Francisca\",,,,\n"
+
+func TestCSVDecoderMapsColumnsToFields(t *testing.T) {
+	dec := search.NewCSVDecoder(strings.NewReader(usersCsv))
+
+	var first map[string]interface{}
+	ok, err := dec.Next(&first)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"_id":             "1",
+		"name":            "Burgess England",
+		"active":          "true",
+		"organization_id": "1",
+		"tags":            "Riceville; Ribera;Caberfae",
+		"unknown":         "x",
+	}, first)
+
+	var second map[string]interface{}
+	ok, err = dec.Next(&second)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"_id": "2", "name": "Rasmussen, Francisca"}, second)
+
+	ok, err = dec.Next(&map[string]interface{}{})
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestCSVDecoderInvalidInput(t *testing.T) {
+	dec := search.NewCSVDecoder(strings.NewReader("_id,name\n1\n"))
+	_, err := dec.Next(&map[string]interface{}{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "wrong number of fields")
+	}
+
+	dec = search.NewCSVDecoder(strings.NewReader(usersCsv))
+	_, err = dec.Next(&struct{}{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot decode CSV into")
+	}
+}
+
+func TestLinesDecoderDecodesEachLine(t *testing.T) {
+	dec := search.NewLinesDecoder(strings.NewReader("{\"_id\": \"1\"}\n\n  {\"_id\": 2}\r\n{\"_id\": 3"))
+
+	var ticket map[string]interface{}
+	ok, err := dec.Next(&ticket)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", ticket["_id"])
+
+	ticket = nil
+	ok, err = dec.Next(&ticket)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, json.Number("2"), ticket["_id"])
+
+	_, err = dec.Next(&map[string]interface{}{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 4")
+	}
+}
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestFileDecoderFormats(t *testing.T) {
+	array := `[{"_id": "1"}, {"_id": "2"}]`
+	lines := "{\"_id\": \"1\"}\n{\"_id\": \"2\"}\n"
+	csv := "_id,subject\n1,A\n2,B\n"
+	tests := []struct {
+		fileName string
+		data     []byte
+	}{
+		{"tickets.json", []byte(array)},
+		{"tickets.jsonl", []byte(lines)},
+		{"tickets.ndjson", []byte(lines)},
+		{"tickets.csv", []byte(csv)},
+		{"TICKETS.CSV", []byte(csv)},
+		{"tickets.json.gz", gzipped(array)},
+		{"tickets.jsonl.gz", gzipped(lines)},
+		{"tickets.csv.gz", gzipped(csv)},
+		{"tickets", []byte("\n  " + array)},
+		{"tickets.txt", []byte(lines)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			dec, err := search.NewFileDecoder(tt.fileName, bytes.NewReader(tt.data))
+			if !assert.NoError(t, err) {
+				return
+			}
+			var ids []string
+			for {
+				var ticket map[string]interface{}
+				ok, err := dec.Next(&ticket)
+				if !assert.NoError(t, err) || !ok {
+					break
+				}
+				ids = append(ids, ticket["_id"].(string))
+			}
+			assert.Equal(t, []string{"1", "2"}, ids)
+		})
+	}
+}
+
+func TestFileDecoderInvalidGzip(t *testing.T) {
+	_, err := search.NewFileDecoder("tickets.json.gz", strings.NewReader(`[{"_id": "1"}]`))
+	assert.Error(t, err)
+}
+
+func TestInitReadsCSVAndJSONLines(t *testing.T) {
+	mfs := &mockFileService{}
+
+	var usersLines strings.Builder
+	var users []map[string]interface{}
+	if err := json.Unmarshal([]byte(usersJson), &users); err != nil {
+		assert.Fail(t, err.Error())
+	}
+	for _, u := range users {
+		b, _ := json.Marshal(u)
+		usersLines.Write(b)
+		usersLines.WriteString("\n")
+	}
+
+	mfs.On("Open", "/export/users.jsonl").Return([]byte(usersLines.String()), nil)
+	mfs.On("Open", "/export/organizations.csv.gz").Return(gzipped("_id,name,domain_names\n1,Limozen,otherway.com;rodeomad.com\n"), nil)
+	mfs.On("Open", "/export/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.NewWithFiles(mfs, search.Files{
+		search.USER_SEARCH:         "/export/users.jsonl",
+		search.ORGANIZATION_SEARCH: "/export/organizations.csv.gz",
+		search.TICKET_SEARCH:       "/export/tickets.json",
+	})
+	err := svc.Init(search.USER_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Search("organization.domain_names", "rodeomad.com")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "Burgess England", result[0]["name"])
+	assert.Equal(t, "Limozen", result[0]["organization"])
+}
+
+// ticketsReader generates a tickets data file of n tickets as it is read, so
+// that the file itself takes no memory. onTicket, when set, is called with the
+// number of each ticket as it is generated.
+type ticketsReader struct {
+	n, i     int
+	buf      strings.Reader
+	onTicket func(i int)
+}
+
+func newTicketsReader(n int) *ticketsReader {
+	r := &ticketsReader{n: n}
+	r.buf.Reset("[")
+	return r
+}
+
+func (r *ticketsReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		switch {
+		case r.i < r.n:
+			sep := ","
+			if r.i == 0 {
+				sep = ""
+			}
+			r.buf.Reset(fmt.Sprintf(`%s{"_id": "%08d-1147-4c0a-8439-6f79833bff5b", "subject": "A Problem in Morocco",`+
+				` "description": "Nostrud ad sit velit cupidatat laboris ipsum nisi amet laboris ex exercitation amet et proident.",`+
+				` "status": "pending", "tags": ["Ohio", "Pennsylvania", "American Samoa"], "submitter_id": %d}`, sep, r.i, r.i%75))
+			if r.onTicket != nil {
+				r.onTicket(r.i)
+			}
+			r.i++
+		case r.i == r.n:
+			r.buf.Reset("]")
+			r.i++
+		default:
+			return 0, io.EOF
+		}
+	}
+	return r.buf.Read(p)
+}
+
+// peakHeap tracks the largest live heap seen while decoding.
+type peakHeap struct {
+	base, peak uint64
+}
+
+func newPeakHeap() *peakHeap {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return &peakHeap{base: m.HeapAlloc}
+}
+
+func (p *peakHeap) sample() {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	if m.HeapAlloc > p.base && m.HeapAlloc-p.base > p.peak {
+		p.peak = m.HeapAlloc - p.base
+	}
+}
+
+func (p *peakHeap) report(b *testing.B) {
+	b.ReportMetric(float64(p.peak)/(1<<20), "peak-heap-MB")
+}
+
+// BenchmarkDecodeTickets compares the memory needed to decode tickets data
+// files of growing size one record at a time with reading and unmarshalling
+// them whole. The peak heap of the former stays flat while that of the latter
+// grows with the file.
+func BenchmarkDecodeTickets(b *testing.B) {
+	const SAMPLE_EVERY = 10000
+	for _, n := range []int{10000, 50000, 200000} {
+		b.Run(fmt.Sprintf("stream/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				heap := newPeakHeap()
+				dec := search.NewArrayDecoder(newTicketsReader(n))
+				for j := 0; ; j++ {
+					var ticket map[string]interface{}
+					ok, err := dec.Next(&ticket)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if !ok {
+						break
+					}
+					if j%SAMPLE_EVERY == 0 {
+						heap.sample()
+					}
+				}
+				heap.report(b)
+			}
+		})
+		b.Run(fmt.Sprintf("unmarshal/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				heap := newPeakHeap()
+				data, err := ioutil.ReadAll(newTicketsReader(n))
+				if err != nil {
+					b.Fatal(err)
+				}
+				var tickets []map[string]interface{}
+				if err = json.Unmarshal(data, &tickets); err != nil {
+					b.Fatal(err)
+				}
+				heap.sample()
+				heap.report(b)
+				runtime.KeepAlive(data)
+				runtime.KeepAlive(tickets)
+			}
+		})
+	}
+}
+
+// generatedFileService opens a tickets data file of n generated tickets, and
+// the users and organizations of the tests.
+type generatedFileService struct {
+	n        int
+	onTicket func(i int)
+}
+
+func (fs *generatedFileService) Open(fileName string) (io.ReadCloser, error) {
+	switch fileName {
+	case "users.json":
+		return ioutil.NopCloser(strings.NewReader(usersJson)), nil
+	case "organizations.json":
+		return ioutil.NopCloser(strings.NewReader(orgsJson)), nil
+	}
+	r := newTicketsReader(fs.n)
+	r.onTicket = fs.onTicket
+	return ioutil.NopCloser(r), nil
+}
+
+// BenchmarkInitTickets measures the peak heap of Init loading tickets data
+// files of growing size. Init keeps the records in an index on disk rather
+// than in memory, so the peak heap stays about flat as the file grows.
+func BenchmarkInitTickets(b *testing.B) {
+	const SAMPLE_EVERY = 1000
+	for _, n := range []int{1000, 5000, 10000} {
+		b.Run(fmt.Sprintf("init/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				heap := newPeakHeap()
+				fs := &generatedFileService{n: n, onTicket: func(j int) {
+					if j%SAMPLE_EVERY == 0 {
+						heap.sample()
+					}
+				}}
+				svc := search.NewWithFiles(fs, validateFiles)
+				if err := svc.Init(search.TICKET_SEARCH); err != nil {
+					b.Fatal(err)
+				}
+				heap.sample()
+				heap.report(b)
+				runtime.KeepAlive(svc)
+			}
+		})
+	}
+}
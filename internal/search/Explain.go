@@ -0,0 +1,188 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	bsearch "github.com/blevesearch/bleve/search"
+)
+
+// Explanation describes why a hit matched and how its score was arrived at.
+// Score breaks the score down into the parts it was computed from. Query holds
+// the terms searched for by each condition on a text or keyword field, and
+// Fields the terms indexed for each field of the hit that matched.
+type Explanation struct {
+	Score  ScoreDetail
+	Query  []Analysis
+	Fields []Analysis
+}
+
+// ScoreDetail is a part of a score, the value of which is computed from its
+// children as described by Message.
+type ScoreDetail struct {
+	Value    float64
+	Message  string
+	Children []ScoreDetail
+}
+
+// Analysis is a value of a field along with the terms it is broken into for
+// searching.
+type Analysis struct {
+	Field string
+	Text  string
+	Terms []string
+}
+
+// Analyze breaks text into the terms that are searched for, or indexed, when
+// it is the value of field. Only text and keyword fields are analyzed.
+func (svc *Service) Analyze(field string, text string) ([]string, error) {
+	f, err := svc.Field(field)
+	if err != nil {
+		return nil, err
+	}
+	switch f.Type {
+	case TEXT_FIELD, KEYWORD_FIELD:
+		return svc.analyze(f.analyzer(), text)
+	}
+	return nil, fmt.Errorf("field %s is a %s field, only text and keyword fields are analyzed", f.Name, f.Type)
+}
+
+func (svc *Service) analyze(analyzerName string, text string) ([]string, error) {
+	analyzer := svc.index.Mapping().AnalyzerNamed(analyzerName)
+	if analyzer == nil {
+		return nil, fmt.Errorf("no analyzer named %s", analyzerName)
+	}
+	terms := make([]string, 0)
+	for _, token := range analyzer.Analyze([]byte(text)) {
+		terms = append(terms, string(token.Term))
+	}
+	return terms, nil
+}
+
+// buildExplanation explains a hit of q, the document of r. The hit must have
+// been searched for with its explanation and term locations included, and r
+// linked to the records of its relations to one record.
+func (svc *Service) buildExplanation(hit *bsearch.DocumentMatch, r *record, q Query) (*Explanation, error) {
+	expl := &Explanation{
+		Query:  make([]Analysis, 0),
+		Fields: make([]Analysis, 0),
+	}
+	if hit.Expl != nil {
+		expl.Score = buildScoreDetail(hit.Expl)
+	}
+
+	for _, c := range svc.analyzedConditions(q) {
+		analysis, err := svc.analyzeCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		expl.Query = append(expl.Query, analysis)
+	}
+
+	record := buildFieldValues(r)
+	for _, name := range matchedFields(hit) {
+		f, err := svc.Field(name)
+		if err != nil || (f.Type != TEXT_FIELD && f.Type != KEYWORD_FIELD) {
+			continue
+		}
+		values, ok := record[name].([]interface{})
+		if !ok {
+			values = []interface{}{record[name]}
+		}
+		for _, v := range values {
+			text := fmt.Sprint(v)
+			terms, err := svc.Analyze(name, text)
+			if err != nil {
+				return nil, err
+			}
+			expl.Fields = append(expl.Fields, Analysis{Field: name, Text: text, Terms: terms})
+		}
+	}
+	return expl, nil
+}
+
+func buildScoreDetail(expl *bsearch.Explanation) ScoreDetail {
+	detail := ScoreDetail{Value: expl.Value, Message: expl.Message}
+	for _, child := range expl.Children {
+		if child != nil {
+			detail.Children = append(detail.Children, buildScoreDetail(child))
+		}
+	}
+	return detail
+}
+
+// matchedFields lists the fields of a hit that matched, in order, mapping the
+// exact and sort copies of text fields back to the fields themselves.
+func matchedFields(hit *bsearch.DocumentMatch) []string {
+	names := make([]string, 0, len(hit.Locations))
+	for name := range hit.Locations {
+		name = strings.TrimPrefix(strings.TrimPrefix(name, EXACT_FIELD_PREFIX), SORT_FIELD_PREFIX)
+		if name != DOC_TYPE_FIELD_NAME && name != EMPTY_FIELD_NAME && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// analyzedConditions lists the conditions of q that search a text or keyword
+// field for a value. Text queries are listed as conditions on ALL_FIELD_NAME.
+func (svc *Service) analyzedConditions(q Query) []Condition {
+	conditions := make([]Condition, 0)
+	switch q := q.(type) {
+	case Condition:
+		f, err := svc.Field(q.Field)
+		if err != nil || q.Empty || q.Range != nil || (f.Type != TEXT_FIELD && f.Type != KEYWORD_FIELD) {
+			break
+		}
+		if len(q.Values) == 0 {
+			conditions = append(conditions, q)
+		}
+		for _, v := range q.Values {
+			single := q
+			single.Value, single.Values = v, nil
+			conditions = append(conditions, single)
+		}
+	case Text:
+		conditions = append(conditions, Condition{Field: ALL_FIELD_NAME, Value: q.Value})
+	case And:
+		for _, sub := range q {
+			conditions = append(conditions, svc.analyzedConditions(sub)...)
+		}
+	case Or:
+		for _, sub := range q {
+			conditions = append(conditions, svc.analyzedConditions(sub)...)
+		}
+	case Not:
+		conditions = append(conditions, svc.analyzedConditions(q.Query)...)
+	}
+	return conditions
+}
+
+// analyzeCondition breaks the value of a condition into the terms searched
+// for, which depend on its match mode as well as the field. Regular
+// expressions and wildcard patterns are searched for as a single term.
+func (svc *Service) analyzeCondition(c Condition) (Analysis, error) {
+	analysis := Analysis{Field: c.Field, Text: c.Value}
+	if c.Field == ALL_FIELD_NAME {
+		terms, err := svc.analyze(en.AnalyzerName, c.Value)
+		analysis.Terms = terms
+		return analysis, err
+	}
+
+	f, err := svc.Field(c.Field)
+	if err != nil {
+		return analysis, err
+	}
+	switch {
+	case f.Type == KEYWORD_FIELD || c.Mode == EXACT_MODE || c.Mode == REGEX_MODE:
+		analysis.Terms = []string{c.Value}
+	case c.Mode == PREFIX_MODE || c.Mode == WILDCARD_MODE:
+		analysis.Terms, err = svc.analyze(SORT_ANALYZER, c.Value)
+	default:
+		analysis.Terms, err = svc.analyze(f.analyzer(), c.Value)
+	}
+	return analysis, err
+}
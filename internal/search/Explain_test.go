@@ -0,0 +1,75 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestTicketFindExplained(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	results, err := svc.Find(search.Request{Query: search.Condition{Field: "subject", Value: "problems"}})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(results.Scores))
+	assert.True(t, results.Scores[0] > 0)
+	assert.Nil(t, results.Explanations)
+
+	results, err = svc.Find(search.Request{
+		Query:   search.And{search.Condition{Field: "subject", Value: "problems"}, search.Condition{Field: "status", Value: "solved"}},
+		Explain: true,
+	})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(results.Explanations))
+	expl := results.Explanations[0]
+	assert.InDelta(t, results.Scores[0], expl.Score.Value, 0.0001)
+	assert.NotEmpty(t, expl.Score.Children)
+	assert.Equal(t, []search.Analysis{
+		{Field: "subject", Text: "problems", Terms: []string{"problem"}},
+		{Field: "status", Text: "solved", Terms: []string{"solv"}},
+	}, expl.Query)
+	assert.Equal(t, []search.Analysis{
+		{Field: "status", Text: "solved", Terms: []string{"solv"}},
+		{Field: "subject", Text: "A Problem in Morocco", Terms: []string{"problem", "morocco"}},
+	}, expl.Fields)
+}
+
+func TestTicketAnalyze(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	terms, err := svc.Analyze("subject", "A Catastrophe in Hungary")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"catastroph", "hungari"}, terms)
+
+	terms, err = svc.Analyze("_id", "2217c7dc-7371-4401-8738-0a8a8aedc08d")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2217c7dc-7371-4401-8738-0a8a8aedc08d"}, terms)
+
+	_, err = svc.Analyze("organization_id", "1")
+	assert.Error(t, err)
+}
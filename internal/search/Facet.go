@@ -0,0 +1,244 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	bsearch "github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+type Interval string
+
+const (
+	DAY_INTERVAL   Interval = "day"
+	MONTH_INTERVAL Interval = "month"
+	YEAR_INTERVAL  Interval = "year"
+)
+
+// Intervals lists the bucket sizes available when faceting a date field.
+var Intervals = []Interval{DAY_INTERVAL, MONTH_INTERVAL, YEAR_INTERVAL}
+
+// MAX_DATE_BUCKETS bounds the number of buckets a date facet may produce.
+const MAX_DATE_BUCKETS = 1000
+
+// FacetRequest counts the records of the current search type by the values of
+// Field. When Query is set only the records matching it are counted. Size
+// limits the number of values returned, zero returning them all. Date fields
+// are counted in buckets of Interval, by month when no interval is given.
+type FacetRequest struct {
+	Field    string
+	Query    Query
+	Size     int
+	Interval Interval
+}
+
+// FacetBucket is the number of records having a value, or for date fields
+// falling within a period.
+type FacetBucket struct {
+	Value string
+	Count int
+}
+
+// FacetResult holds the counts for a facet. Total is the number of records
+// counted, Missing the number of those without a value for the field and
+// Other the number of times the values left out because of the requested size
+// are used. Other counts values rather than records, so for list fields it can
+// exceed the number of records.
+type FacetResult struct {
+	Field   string
+	Total   uint64
+	Missing int
+	Other   int
+	Buckets []FacetBucket
+}
+
+// Facet counts the records of the current search type by field value.
+func (svc *Service) Facet(req FacetRequest) (*FacetResult, error) {
+	field, err := svc.Field(req.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	searchQuery, err := svc.buildSearchQuery(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var facet *bleve.FacetRequest
+	switch field.Type {
+	case TEXT_FIELD:
+		facet, err = svc.buildTermFacet(EXACT_FIELD_PREFIX+field.Name, req.Size)
+	case KEYWORD_FIELD, BOOLEAN_FIELD:
+		facet, err = svc.buildTermFacet(field.Name, req.Size)
+	case DATETIME_FIELD:
+		facet, err = svc.buildDateFacet(field, searchQuery, req.Interval)
+	default:
+		return nil, fmt.Errorf("field %s is a %s field, counts are only supported on text, boolean and date fields", field.Name, field.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(searchQuery, 0, 0, false)
+	if facet != nil {
+		searchRequest.AddFacet(field.Name, facet)
+	}
+	searchResult, err := svc.index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	facetResult, ok := searchResult.Facets[field.Name]
+	if !ok {
+		// None of the records has a date to bucket.
+		return &FacetResult{Field: field.Name, Total: searchResult.Total, Missing: int(searchResult.Total), Buckets: make([]FacetBucket, 0)}, nil
+	}
+	result := &FacetResult{
+		Field:   field.Name,
+		Total:   searchResult.Total,
+		Missing: facetResult.Missing,
+		Other:   facetResult.Other,
+		Buckets: make([]FacetBucket, 0),
+	}
+	for _, term := range facetResult.Terms {
+		value := term.Term
+		if field.Type == BOOLEAN_FIELD {
+			value = fmt.Sprint(value == "T")
+		}
+		result.Buckets = append(result.Buckets, FacetBucket{Value: value, Count: term.Count})
+	}
+
+	// Date buckets are listed in order, including those without any records.
+	counts := make(map[string]int)
+	for _, r := range facetResult.DateRanges {
+		counts[r.Name] = r.Count
+	}
+	for _, r := range facet.DateTimeRanges {
+		result.Buckets = append(result.Buckets, FacetBucket{Value: r.Name, Count: counts[r.Name]})
+	}
+	return result, nil
+}
+
+// buildTermFacet counts the values of an index field, returning size of them,
+// or all of them when size is not positive.
+func (svc *Service) buildTermFacet(indexField string, size int) (*bleve.FacetRequest, error) {
+	if size > 0 {
+		return bleve.NewFacetRequest(indexField, size), nil
+	}
+
+	// Every value indexed in the field, for any type, is a bucket at most.
+	dict, err := svc.index.FieldDict(indexField)
+	if err != nil {
+		return nil, err
+	}
+	defer dict.Close()
+	size = 0
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		size++
+	}
+	if size == 0 {
+		size = 1
+	}
+	return bleve.NewFacetRequest(indexField, size), nil
+}
+
+// buildDateFacet buckets a date field by interval between the earliest and
+// latest dates of the records matching searchQuery. No facet is returned when
+// none of the records has a date.
+func (svc *Service) buildDateFacet(field Field, searchQuery query.Query, interval Interval) (*bleve.FacetRequest, error) {
+	if interval == "" {
+		interval = MONTH_INTERVAL
+	}
+	format, err := intervalFormat(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	first, ok, err := svc.findDate(field, searchQuery, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+	last, _, err := svc.findDate(field, searchQuery, true)
+	if err != nil {
+		return nil, err
+	}
+
+	facet := bleve.NewFacetRequest(field.Name, MAX_DATE_BUCKETS)
+	for start := truncateDate(first.UTC(), interval); !start.After(last); start = nextDate(start, interval) {
+		if len(facet.DateTimeRanges) == MAX_DATE_BUCKETS {
+			return nil, fmt.Errorf("too many %s buckets for field %s, try a longer interval", interval, field.Name)
+		}
+		facet.AddDateTimeRange(start.Format(format), start, nextDate(start, interval))
+	}
+	return facet, nil
+}
+
+// findDate returns the earliest, or when last is set the latest, value of a
+// date field among the records matching searchQuery.
+func (svc *Service) findDate(field Field, searchQuery query.Query, last bool) (time.Time, bool, error) {
+	searchRequest := bleve.NewSearchRequestOptions(searchQuery, 1, 0, false)
+	searchRequest.SortByCustom(bsearch.SortOrder{buildSortField(field, last)})
+	searchRequest.Fields = []string{SOURCE_FIELD_NAME}
+	searchResult, err := svc.index.Search(searchRequest)
+	if err != nil || len(searchResult.Hits) == 0 {
+		return time.Time{}, false, err
+	}
+
+	record, err := svc.newLinker().hit(searchResult.Hits[0], false)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	value, _ := buildFieldValues(record)[field.Name].(string)
+	t, _, err := parseDateTime(value)
+	if err != nil {
+		// The earliest or latest record sorted without a value.
+		return time.Time{}, false, nil
+	}
+	return t, true, nil
+}
+
+func intervalFormat(interval Interval) (string, error) {
+	switch interval {
+	case DAY_INTERVAL:
+		return DATE_LAYOUT, nil
+	case MONTH_INTERVAL:
+		return "2006-01", nil
+	case YEAR_INTERVAL:
+		return "2006", nil
+	}
+	names := make([]string, len(Intervals))
+	for i, in := range Intervals {
+		names[i] = string(in)
+	}
+	return "", fmt.Errorf("unknown interval %q, expected one of %s", interval, strings.Join(names, ", "))
+}
+
+func truncateDate(t time.Time, interval Interval) time.Time {
+	switch interval {
+	case YEAR_INTERVAL:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	case MONTH_INTERVAL:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func nextDate(t time.Time, interval Interval) time.Time {
+	switch interval {
+	case YEAR_INTERVAL:
+		return t.AddDate(1, 0, 0)
+	case MONTH_INTERVAL:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
@@ -0,0 +1,76 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestTicketFacetByTerm(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Facet(search.FacetRequest{Field: "priority"})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, uint64(2), result.Total)
+	assert.ElementsMatch(t, []search.FacetBucket{{Value: "normal", Count: 1}, {Value: "urgent", Count: 1}}, result.Buckets)
+
+	result, err = svc.Facet(search.FacetRequest{Field: "tags", Query: search.Condition{Field: "via", Value: "web"}})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, uint64(1), result.Total)
+	assert.ElementsMatch(t, []search.FacetBucket{{Value: "Massachusetts", Count: 1}, {Value: "New York", Count: 1}, {Value: "Minnesota", Count: 1}, {Value: "New Jersey", Count: 1}}, result.Buckets)
+
+	result, err = svc.Facet(search.FacetRequest{Field: "has_incidents"})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, []search.FacetBucket{{Value: "true", Count: 2}}, result.Buckets)
+
+	_, err = svc.Facet(search.FacetRequest{Field: "organization_id"})
+	assert.Error(t, err)
+}
+
+func TestTicketFacetByDate(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Facet(search.FacetRequest{Field: "created_at", Interval: search.DAY_INTERVAL})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 11, len(result.Buckets))
+	assert.Equal(t, search.FacetBucket{Value: "2016-07-06", Count: 1}, result.Buckets[0])
+	assert.Equal(t, search.FacetBucket{Value: "2016-07-16", Count: 1}, result.Buckets[10])
+
+	result, err = svc.Facet(search.FacetRequest{Field: "due_at"})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, []search.FacetBucket{{Value: "2016-08", Count: 2}}, result.Buckets)
+
+	_, err = svc.Facet(search.FacetRequest{Field: "due_at", Interval: "fortnight"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/datetime/flexible"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+type FieldType string
+
+const (
+	TEXT_FIELD     FieldType = "text"
+	KEYWORD_FIELD  FieldType = "keyword"
+	NUMERIC_FIELD  FieldType = "numeric"
+	BOOLEAN_FIELD  FieldType = "boolean"
+	DATETIME_FIELD FieldType = "datetime"
+)
+
+// RELATION_SEPARATOR separates the relation from the field of the related
+// entity in the name of a relationship field.
+const RELATION_SEPARATOR = "."
+
+// EMPTY_FIELD_NAME is the index field listing the fields of a document that
+// are missing or blank.
+const EMPTY_FIELD_NAME = "_empty"
+
+// EXACT_FIELD_PREFIX prefixes the index fields holding the unanalyzed value of
+// a text field.
+const EXACT_FIELD_PREFIX = "_exact."
+
+// DATETIME_LAYOUT is the timestamp format used throughout the data files.
+const DATETIME_LAYOUT = "2006-01-02T15:04:05 -07:00"
+
+const DATETIME_PARSER = "zendesk"
+
+// DATE_LAYOUT allows dates to be searched without a time of day.
+const DATE_LAYOUT = "2006-01-02"
+
+var datetimeLayouts = []string{DATETIME_LAYOUT, time.RFC3339, "2006-01-02T15:04:05"}
+
+// Field describes a searchable field and how it is indexed. Array is set on
+// fields holding a list of values. Relation is set on the fields of a related
+// entity, e.g. "organization" for the field organization.name of a ticket.
+// Values lists the values allowed for the field when only some are. Analyzer
+// names the analyzer of a text field, en unless given. Inferred is set on the
+// fields found in the data files that the schema does not declare.
+type Field struct {
+	Name     string    `yaml:"name"`
+	Type     FieldType `yaml:"type"`
+	Array    bool      `yaml:"list,omitempty"`
+	Relation string    `yaml:"-"`
+	Values   []string  `yaml:"values,omitempty,flow"`
+	Analyzer string    `yaml:"analyzer,omitempty"`
+	Inferred bool      `yaml:"-"`
+}
+
+// analyzer returns the name of the analyzer of a text or keyword field.
+func (f Field) analyzer() string {
+	switch {
+	case f.Type == KEYWORD_FIELD:
+		return keyword.Name
+	case f.Analyzer != "":
+		return f.Analyzer
+	}
+	return en.AnalyzerName
+}
+
+func buildFieldMapping(field Field) *mapping.FieldMapping {
+	var fm *mapping.FieldMapping
+	switch field.Type {
+	case NUMERIC_FIELD:
+		fm = bleve.NewNumericFieldMapping()
+	case BOOLEAN_FIELD:
+		fm = bleve.NewBooleanFieldMapping()
+	case DATETIME_FIELD:
+		fm = bleve.NewDateTimeFieldMapping()
+		fm.DateFormat = DATETIME_PARSER
+	default:
+		fm = bleve.NewTextFieldMapping()
+		fm.Analyzer = field.analyzer()
+	}
+	return fm
+}
+
+// addFieldMappings adds the mappings of a field, those of relationship fields
+// being added to the sub-document of the relation. The documents themselves
+// are flat, so relationship fields are indexed under their full name.
+func addFieldMappings(docMapping *mapping.DocumentMapping, field Field, fms ...*mapping.FieldMapping) {
+	if field.Relation == "" {
+		docMapping.AddFieldMappingsAt(field.Name, fms...)
+		return
+	}
+	relationMapping, ok := docMapping.Properties[field.Relation]
+	if !ok {
+		relationMapping = bleve.NewDocumentMapping()
+		docMapping.AddSubDocumentMapping(field.Relation, relationMapping)
+	}
+	for _, fm := range fms {
+		// Matches on related entities are left out of searches of all fields,
+		// otherwise every ticket of an organization would match its name.
+		fm.IncludeInAll = false
+	}
+	relationMapping.AddFieldMappingsAt(strings.TrimPrefix(field.Name, field.Relation+RELATION_SEPARATOR), fms...)
+}
+
+// buildExactFieldMapping indexes the whole, unanalyzed value of a text field
+// alongside its tokens, e.g. for counting distinct values.
+func buildExactFieldMapping(field Field) *mapping.FieldMapping {
+	fm := buildFieldMapping(Field{Type: KEYWORD_FIELD})
+	fm.Name = EXACT_FIELD_PREFIX + field.Name
+	fm.Store = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	return fm
+}
+
+func buildInternalFieldMapping() *mapping.FieldMapping {
+	fm := buildFieldMapping(Field{Type: KEYWORD_FIELD})
+	fm.IncludeInAll = false
+	return fm
+}
+
+func buildDocumentMapping(fields []Field) *mapping.DocumentMapping {
+	docMapping := bleve.NewDocumentMapping()
+	for _, f := range fields {
+		if f.Type == TEXT_FIELD {
+			addFieldMappings(docMapping, f, buildFieldMapping(f), buildExactFieldMapping(f), buildSortFieldMapping(f))
+		} else {
+			addFieldMappings(docMapping, f, buildFieldMapping(f))
+		}
+	}
+	// The doc type and empty field names are left out of the composite field
+	// searched by Text so that searching for "ticket" or "tags" does not
+	// match every ticket or every record without tags.
+	docMapping.AddFieldMappingsAt(DOC_TYPE_FIELD_NAME, buildInternalFieldMapping())
+	docMapping.AddFieldMappingsAt(EMPTY_FIELD_NAME, buildInternalFieldMapping())
+	docMapping.AddFieldMappingsAt(SOURCE_FIELD_NAME, buildSourceFieldMapping())
+	return docMapping
+}
+
+func addDateTimeParser(indexMapping *mapping.IndexMappingImpl) error {
+	layouts := make([]interface{}, len(datetimeLayouts))
+	for i, l := range datetimeLayouts {
+		layouts[i] = l
+	}
+	return indexMapping.AddCustomDateTimeParser(DATETIME_PARSER, map[string]interface{}{
+		"type":    flexible.Name,
+		"layouts": layouts,
+	})
+}
+
+func buildEmptyQuery(field Field) query.Query {
+	q := bleve.NewTermQuery(field.Name)
+	q.SetField(EMPTY_FIELD_NAME)
+	return q
+}
+
+// buildFieldQuery parses value according to the type of field and returns a
+// query matching documents with that value.
+func buildFieldQuery(field Field, value string) (query.Query, error) {
+	switch field.Type {
+	case NUMERIC_FIELD:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q for field %s", value, field.Name)
+		}
+		inclusive := true
+		q := bleve.NewNumericRangeInclusiveQuery(&n, &n, &inclusive, &inclusive)
+		q.SetField(field.Name)
+		return q, nil
+	case BOOLEAN_FIELD:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q for field %s, expected true or false", value, field.Name)
+		}
+		q := bleve.NewBoolFieldQuery(b)
+		q.SetField(field.Name)
+		return q, nil
+	case DATETIME_FIELD:
+		start, end, err := parseDateTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q for field %s: %v", value, field.Name, err)
+		}
+		startInclusive, endInclusive := true, end.Equal(start)
+		q := bleve.NewDateRangeInclusiveQuery(start, end, &startInclusive, &endInclusive)
+		q.SetField(field.Name)
+		return q, nil
+	case KEYWORD_FIELD:
+		q := bleve.NewTermQuery(value)
+		q.SetField(field.Name)
+		return q, nil
+	default:
+		q := bleve.NewMatchQuery(value)
+		q.SetField(field.Name)
+		q.Analyzer = field.analyzer()
+		return q, nil
+	}
+}
+
+// parseDateTime parses a timestamp in any of the supported layouts and returns
+// the instant it denotes as both start and end. A date without a time of day
+// denotes the whole day in UTC, returned as the half open range [start, end).
+func parseDateTime(value string) (time.Time, time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range datetimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, t, nil
+		}
+	}
+	t, err := time.Parse(DATE_LAYOUT, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("expected a date like %q or %q", DATE_LAYOUT, DATETIME_LAYOUT)
+	}
+	return t, t.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,167 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	bsearch "github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// ALL_FIELD_NAME is the composite index field holding the values of every
+// field of a record.
+const ALL_FIELD_NAME = "_all"
+
+// Text matches the records having all the words of Value in any of their
+// own fields, or having Value as the whole of a keyword or numeric field. It is
+// meant for values of an unknown kind, such as an email address, an
+// external_id or a name.
+type Text struct {
+	Value string
+}
+
+func (t Text) build(svc *Service) (query.Query, error) {
+	value := strings.TrimSpace(t.Value)
+
+	words := bleve.NewMatchQuery(value)
+	words.SetField(ALL_FIELD_NAME)
+	words.Analyzer = en.AnalyzerName
+	words.SetOperator(query.MatchQueryOperatorAnd)
+	queries := []query.Query{words}
+
+	for _, f := range svc.fieldDefinitions() {
+		if f.Relation != "" {
+			continue
+		}
+		switch f.Type {
+		case KEYWORD_FIELD:
+			q := bleve.NewTermQuery(value)
+			q.SetField(f.Name)
+			queries = append(queries, q)
+		case NUMERIC_FIELD:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+			q, err := buildFieldQuery(f, value)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, q)
+		}
+	}
+	return bleve.NewDisjunctionQuery(queries...), nil
+}
+
+// GroupResults holds the hits of a global search for one search type, along
+// with the number of records of that type that matched. Scores, Fragments
+// and Explanations are as for Results.
+type GroupResults struct {
+	Type         Type
+	DocType      DocType
+	Total        uint64
+	Hits         []map[string]interface{}
+	Scores       []float64
+	Fragments    []map[string][]string
+	Explanations []*Explanation
+}
+
+// GlobalResults holds the hits of a global search grouped by search type, in
+// the order the types are declared by the schema.
+type GlobalResults struct {
+	Total  uint64
+	Groups []GroupResults
+}
+
+// SearchAll runs the query of req against the records of every search type
+// that Init indexed in a single search, returning up to req.Limit hits of
+// each type ordered by relevance. A limit of zero or less returns every hit.
+// Types lacking a field used by the query are left out, an error being
+// returned only when no type has it. Results cannot be sorted or paged.
+func (svc *Service) SearchAll(req Request) (*GlobalResults, error) {
+	if req.Offset != 0 || len(req.Sort) > 0 {
+		return nil, fmt.Errorf("a search of all types cannot be sorted or paged")
+	}
+
+	queries := make([]query.Query, 0, len(svc.schema.Types))
+	fields := make([]Field, 0)
+	for _, t := range svc.schema.Types {
+		// The records of the types that are only loaded to be shown with
+		// others are in the index too, but are not searched.
+		if !svc.searched[t.Name] {
+			continue
+		}
+		typed := *svc
+		typed.searchType = t.Name
+		fields = append(fields, typed.fieldDefinitions()...)
+		typeQuery, err := typed.buildSearchQuery(req.Query)
+		if errors.Is(err, ErrUnknownField) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, typeQuery)
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%w, none of %s has all of the fields searched", ErrUnknownField, strings.Join(svc.schema.TypeNames(), ", "))
+	}
+
+	count, err := svc.index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	searchRequest := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(queries...), int(count), 0, false)
+	searchRequest.SortByCustom(bsearch.SortOrder{&bsearch.SortScore{Desc: true}, &bsearch.SortDocID{}})
+	if searchRequest.Highlight, err = buildHighlight(req.Highlight, fields); err != nil {
+		return nil, err
+	}
+	searchRequest.Explain = req.Explain
+	searchRequest.IncludeLocations = req.Explain
+	searchRequest.Fields = []string{SOURCE_FIELD_NAME}
+	searchResult, err := svc.index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[DocType]*GroupResults)
+	results := &GlobalResults{Total: searchResult.Total, Groups: make([]GroupResults, 0, len(svc.schema.Types))}
+	for _, t := range svc.schema.Types {
+		results.Groups = append(results.Groups, GroupResults{Type: t.Name, DocType: t.DocType, Hits: make([]map[string]interface{}, 0), Scores: make([]float64, 0)})
+		groups[t.DocType] = &results.Groups[len(results.Groups)-1]
+	}
+	links := svc.newLinker()
+	for _, hit := range searchResult.Hits {
+		record, err := links.hit(hit, true)
+		if err != nil {
+			return nil, err
+		}
+		group := groups[record.entity.DocType]
+		group.Total++
+		if req.Limit > 0 && len(group.Hits) >= req.Limit {
+			continue
+		}
+		if req.Nested {
+			group.Hits = append(group.Hits, buildNestedResult(record, buildTypedResult))
+		} else {
+			group.Hits = append(group.Hits, buildRecordResult(record))
+		}
+		group.Scores = append(group.Scores, hit.Score)
+		if searchRequest.Highlight != nil {
+			group.Fragments = append(group.Fragments, buildFragments(hit))
+		}
+		if req.Explain {
+			typed := *svc
+			typed.searchType = group.Type
+			expl, err := typed.buildExplanation(hit, record, req.Query)
+			if err != nil {
+				return nil, err
+			}
+			group.Explanations = append(group.Explanations, expl)
+		}
+	}
+	return results, nil
+}
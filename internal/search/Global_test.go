@@ -0,0 +1,65 @@
+package search_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestSearchAll(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init("")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		name     string
+		query    search.Query
+		expected []uint64
+	}{
+		{"email", search.Text{Value: "nealengland@flotonic.com"}, []uint64{1, 0, 0}},
+		{"name", search.Text{Value: "burgess england"}, []uint64{1, 0, 0}},
+		{"ticket id", search.Text{Value: "2217c7dc-7371-4401-8738-0a8a8aedc08d"}, []uint64{0, 1, 0}},
+		{"organization external id", search.Text{Value: "6970300e-f211-4c01-a538-70b4464a1d84"}, []uint64{0, 0, 1}},
+		{"number", search.Text{Value: "1"}, []uint64{1, 2, 1}},
+		{"all words", search.Text{Value: "burgess rasmussen"}, []uint64{0, 0, 0}},
+		{"field", search.Condition{Field: "tags", Value: "Ferguson"}, []uint64{0, 0, 1}},
+		{"field of one type", search.Condition{Field: "subject", Value: "Hungary"}, []uint64{0, 1, 0}},
+	}
+
+	for _, test := range tests {
+		results, err := svc.SearchAll(search.Request{Query: test.query})
+		if err != nil {
+			assert.Fail(t, err.Error(), test.name)
+			continue
+		}
+		var total uint64
+		for i, group := range results.Groups {
+			assert.Equal(t, svc.Schema().Types[i].Name, group.Type, test.name)
+			assert.Equal(t, test.expected[i], group.Total, test.name)
+			assert.Equal(t, int(test.expected[i]), len(group.Hits), test.name)
+			total += test.expected[i]
+		}
+		assert.Equal(t, total, results.Total, test.name)
+	}
+
+	results, err := svc.SearchAll(search.Request{Query: search.Text{Value: "1"}, Limit: 1})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, search.TICKET_DOC_TYPE, results.Groups[1].DocType)
+	assert.Equal(t, uint64(2), results.Groups[1].Total)
+	assert.Equal(t, 1, len(results.Groups[1].Hits))
+
+	_, err = svc.SearchAll(search.Request{Query: search.Condition{Field: "bogus", Value: "1"}})
+	assert.True(t, errors.Is(err, search.ErrUnknownField))
+}
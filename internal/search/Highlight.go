@@ -0,0 +1,108 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/registry"
+	bsearch "github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/highlight"
+	"github.com/blevesearch/bleve/search/highlight/fragmenter/simple"
+	simpleHighlighter "github.com/blevesearch/bleve/search/highlight/highlighter/simple"
+
+	// Registers the ansi highlighter.
+	_ "github.com/blevesearch/bleve/search/highlight/highlighter/ansi"
+)
+
+// HighlightStyle sets how the matched terms of a highlighted fragment are
+// emphasised.
+type HighlightStyle string
+
+const (
+	// ANSI_HIGHLIGHT emphasises matched terms with terminal colours.
+	ANSI_HIGHLIGHT HighlightStyle = "ansi"
+	// MARKER_HIGHLIGHT surrounds matched terms with HIGHLIGHT_MARKER.
+	MARKER_HIGHLIGHT HighlightStyle = "markers"
+)
+
+// HIGHLIGHT_MARKER surrounds the matched terms of fragments highlighted with
+// MARKER_HIGHLIGHT, e.g. "A **Problem** in Morocco".
+const HIGHLIGHT_MARKER = "**"
+
+func init() {
+	registry.RegisterFragmentFormatter(string(MARKER_HIGHLIGHT), func(config map[string]interface{}, cache *registry.Cache) (highlight.FragmentFormatter, error) {
+		return &markerFormatter{before: HIGHLIGHT_MARKER, after: HIGHLIGHT_MARKER}, nil
+	})
+	registry.RegisterHighlighter(string(MARKER_HIGHLIGHT), func(config map[string]interface{}, cache *registry.Cache) (highlight.Highlighter, error) {
+		fragmenter, err := cache.FragmenterNamed(simple.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error building fragmenter: %v", err)
+		}
+		formatter, err := cache.FragmentFormatterNamed(string(MARKER_HIGHLIGHT))
+		if err != nil {
+			return nil, fmt.Errorf("error building fragment formatter: %v", err)
+		}
+		return simpleHighlighter.NewHighlighter(fragmenter, formatter, simpleHighlighter.DefaultSeparator), nil
+	})
+}
+
+// markerFormatter surrounds the matched terms of a fragment with plain text
+// markers, leaving the rest of the fragment as it is.
+type markerFormatter struct {
+	before string
+	after  string
+}
+
+func (m *markerFormatter) Format(f *highlight.Fragment, orderedTermLocations highlight.TermLocations) string {
+	var b strings.Builder
+	curr := f.Start
+	for _, termLocation := range orderedTermLocations {
+		if termLocation == nil || !termLocation.ArrayPositions.Equals(f.ArrayPositions) || termLocation.Start < curr {
+			continue
+		}
+		if termLocation.End > f.End {
+			break
+		}
+		b.Write(f.Orig[curr:termLocation.Start])
+		b.WriteString(m.before)
+		b.Write(f.Orig[termLocation.Start:termLocation.End])
+		b.WriteString(m.after)
+		curr = termLocation.End
+	}
+	b.Write(f.Orig[curr:f.End])
+	return b.String()
+}
+
+// buildHighlight requests fragments of the text and keyword fields of the
+// given fields in style, or no highlighting when style is not set.
+func buildHighlight(style HighlightStyle, fields []Field) (*bleve.HighlightRequest, error) {
+	switch style {
+	case "":
+		return nil, nil
+	case ANSI_HIGHLIGHT, MARKER_HIGHLIGHT:
+	default:
+		return nil, fmt.Errorf("unknown highlight style %q, expected %s or %s", style, ANSI_HIGHLIGHT, MARKER_HIGHLIGHT)
+	}
+
+	h := bleve.NewHighlightWithStyle(string(style))
+	for _, f := range fields {
+		if (f.Type == TEXT_FIELD || f.Type == KEYWORD_FIELD) && !contains(h.Fields, f.Name) {
+			h.AddField(f.Name)
+		}
+	}
+	return h, nil
+}
+
+// buildFragments returns the highlighted fragments of the fields of a hit that
+// matched. Fragments are returned for every highlighted field whether or not
+// it matched, so those without term locations are left out.
+func buildFragments(hit *bsearch.DocumentMatch) map[string][]string {
+	fragments := make(map[string][]string)
+	for field, f := range hit.Fragments {
+		if len(hit.Locations[field]) > 0 {
+			fragments[field] = f
+		}
+	}
+	return fragments
+}
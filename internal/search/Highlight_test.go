@@ -0,0 +1,74 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestTicketFindHighlighted(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	results, err := svc.Find(search.Request{
+		Query:     search.And{search.Condition{Field: "subject", Value: "problem"}, search.Condition{Field: "tags", Value: "Texas"}},
+		Highlight: search.MARKER_HIGHLIGHT,
+	})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(results.Fragments))
+	assert.Equal(t, map[string][]string{
+		"subject": {"A **Problem** in Morocco"},
+		"tags":    {"**Texas**"},
+	}, results.Fragments[0])
+
+	results, err = svc.Find(search.Request{
+		Query:     search.Condition{Field: "description", Value: "velit"},
+		Highlight: search.ANSI_HIGHLIGHT,
+	})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Contains(t, results.Fragments[0]["description"][0], "\x1b[43mvelit\x1b[0m")
+
+	results, err = svc.Find(search.Request{Query: search.Condition{Field: "subject", Value: "problem"}})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Nil(t, results.Fragments)
+
+	_, err = svc.Find(search.Request{Query: search.Condition{Field: "subject", Value: "problem"}, Highlight: "bold"})
+	assert.Error(t, err)
+}
+
+func TestSearchAllHighlighted(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init("")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	results, err := svc.SearchAll(search.Request{Query: search.Text{Value: "Limozen"}, Highlight: search.MARKER_HIGHLIGHT})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	organizations := results.Groups[2]
+	assert.Equal(t, map[string][]string{"name": {"**Limozen**"}}, organizations.Fragments[0])
+}
@@ -0,0 +1,176 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// inferredField collects the types of the values of a field that the schema
+// does not declare.
+type inferredField struct {
+	fieldType FieldType
+	array     bool
+	// skipped is set when the field holds objects, which are not indexed.
+	skipped bool
+}
+
+// add widens the type of the field to hold value. Values of different types
+// widen the field to text.
+func (f *inferredField) add(value interface{}, fromCSV bool) {
+	if elems, ok := value.([]interface{}); ok {
+		f.array = true
+		for _, e := range elems {
+			f.addScalar(e, fromCSV)
+		}
+		return
+	}
+	f.addScalar(value, fromCSV)
+}
+
+func (f *inferredField) addScalar(value interface{}, fromCSV bool) {
+	var t FieldType
+	switch v := value.(type) {
+	case nil:
+		return
+	case json.Number:
+		t = NUMERIC_FIELD
+	case bool:
+		t = BOOLEAN_FIELD
+	case string:
+		t = inferStringType(strings.TrimSpace(v), fromCSV)
+		if t == "" {
+			return
+		}
+	default:
+		f.skipped = true
+		return
+	}
+
+	switch f.fieldType {
+	case "":
+		f.fieldType = t
+	case t:
+	default:
+		f.fieldType = TEXT_FIELD
+	}
+}
+
+// inferStringType returns the type of a string value, or "" when it is blank.
+// The values of CSV files are all strings, so numbers and booleans are
+// recognised from their text there.
+func inferStringType(s string, fromCSV bool) FieldType {
+	switch {
+	case s == "":
+		return ""
+	case fromCSV && (s == "true" || s == "false"):
+		return BOOLEAN_FIELD
+	case fromCSV && isNumber(s):
+		return NUMERIC_FIELD
+	case isDateTime(s):
+		return DATETIME_FIELD
+	}
+	return TEXT_FIELD
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// fieldInference infers the fields of t that the schema does not declare,
+// e.g. custom fields added to an export, from the values of its records so
+// that they can be indexed too. Fields that only ever hold null or blank
+// values, hold objects, or have names that cannot be searched for, are left
+// out.
+type fieldInference struct {
+	t     *EntityType
+	found map[string]*inferredField
+}
+
+func newFieldInference(t *EntityType) *fieldInference {
+	return &fieldInference{t: t, found: make(map[string]*inferredField)}
+}
+
+// add widens the fields inferred to hold the values of a record.
+func (fi *fieldInference) add(raw map[string]interface{}, fromCSV bool) {
+	for name, value := range raw {
+		if fi.t.field(name) != nil || !inferable(fi.t, name) {
+			continue
+		}
+		f, ok := fi.found[name]
+		if !ok {
+			f = &inferredField{}
+			fi.found[name] = f
+		}
+		f.add(value, fromCSV)
+	}
+}
+
+// fields returns the fields inferred, ordered by name.
+func (fi *fieldInference) fields() []Field {
+	fields := make([]Field, 0)
+	for name, f := range fi.found {
+		if f.skipped || f.fieldType == "" {
+			continue
+		}
+		fields = append(fields, Field{Name: name, Type: f.fieldType, Array: f.array, Inferred: true})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// inferFields reads the data files of types for the fields that the schema
+// does not declare, without indexing any records.
+func (svc *Service) inferFields(types map[Type]bool) (map[Type][]Field, error) {
+	extra := make(map[Type][]Field)
+	for _, t := range svc.declared.Types {
+		if !types[t.Name] {
+			continue
+		}
+		inference := newFieldInference(t)
+		err := svc.readRaw(t, func(n int, raw map[string]interface{}, fromCSV bool) error {
+			inference.add(raw, fromCSV)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if fields := inference.fields(); len(fields) > 0 {
+			extra[t.Name] = fields
+		}
+	}
+	return extra, nil
+}
+
+// inferable reports whether an undeclared field named name can be added to t.
+func inferable(t *EntityType, name string) bool {
+	if name == "" || name == DOC_TYPE_FIELD_NAME || strings.Contains(name, RELATION_SEPARATOR) || strings.HasPrefix(name, "_") {
+		return false
+	}
+	for _, r := range t.Relations {
+		if r.Name == name {
+			return false
+		}
+	}
+	return true
+}
+
+// withFields returns a copy of the schema with the extra fields of each type
+// added after those it declares.
+func (s *Schema) withFields(extra map[Type][]Field) (*Schema, error) {
+	extended := &Schema{Types: make([]*EntityType, len(s.Types))}
+	for i, t := range s.Types {
+		copied := *t
+		copied.Fields = append(append(make([]Field, 0, len(t.Fields)+len(extra[t.Name])), t.Fields...), extra[t.Name]...)
+		extended.Types[i] = &copied
+	}
+	if err := extended.compile(); err != nil {
+		return nil, fmt.Errorf("cannot add the fields found in the data files: %v", err)
+	}
+	return extended, nil
+}
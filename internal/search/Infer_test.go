@@ -0,0 +1,130 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+var customUsersJson = `[
+	{"_id": 1, "name": "Burgess England", "organization_id": 1, "seats": 12, "renewal_at": "2016-07-01T05:18:00 -10:00",
+	 "team": "Billing", "badge": 7, "languages": ["en", "fr"], "address": {"city": "Ribera"}, "organization": "Acme", "DocType": "admin"},
+	{"_id": 2, "name": "Nobody", "seats": 3, "badge": "gold", "languages": "de", "nickname": null}
+]`
+
+func TestInitIndexesUndeclaredFields(t *testing.T) {
+	svc := newValidateService(customUsersJson, orgsJson, ticketsJson)
+	if err := svc.Init(search.USER_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	fields := svc.ListFields()
+	assert.Equal(t, append(userFields, "badge", "languages", "renewal_at", "seats", "team"), fields[:len(userFields)+5])
+
+	tests := []struct {
+		field     string
+		fieldType search.FieldType
+		array     bool
+	}{
+		{"badge", search.TEXT_FIELD, false},
+		{"languages", search.TEXT_FIELD, true},
+		{"renewal_at", search.DATETIME_FIELD, false},
+		{"seats", search.NUMERIC_FIELD, false},
+		{"team", search.TEXT_FIELD, false},
+	}
+	for _, tt := range tests {
+		f, err := svc.Field(tt.field)
+		if err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		assert.Equal(t, tt.fieldType, f.Type, tt.field)
+		assert.Equal(t, tt.array, f.Array, tt.field)
+	}
+	for _, skipped := range []string{"address", "nickname", "DocType"} {
+		assert.Error(t, svc.ValidateField(skipped))
+	}
+
+	result, err := svc.Search("seats", ">5")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "12", result[0]["seats"])
+	assert.Equal(t, "7", result[0]["badge"])
+	assert.Equal(t, "Billing", result[0]["team"])
+	assert.Equal(t, "Limozen", result[0]["organization"])
+
+	result, err = svc.Search("languages", "de")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, []interface{}{"de"}, result[0]["languages"])
+	assert.Equal(t, "", result[0]["team"])
+
+	result, err = svc.Search("renewal_at", "2016-07-01")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+}
+
+func TestInitIndexesUndeclaredFieldsOfRelatedRecords(t *testing.T) {
+	svc := newValidateService(customUsersJson, orgsJson, ticketsJson)
+	if err := svc.Init(search.TICKET_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Contains(t, svc.ListFields(), "submitter.team")
+
+	result, err := svc.Search("submitter.team", "billing")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.NotEmpty(t, result)
+}
+
+func TestInitInfersCSVFieldTypes(t *testing.T) {
+	mfs := &mockFileService{}
+	mfs.On("Open", "users.csv").Return([]byte("_id,name,seats,trial,team\n1,Burgess England,12,true,Billing\n2,Nobody,,false,3\n"), nil)
+	mfs.On("Open", "organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "tickets.json").Return([]byte("[]"), nil)
+	svc := search.NewWithFiles(mfs, search.Files{
+		search.USER_SEARCH:         "users.csv",
+		search.ORGANIZATION_SEARCH: "organizations.json",
+		search.TICKET_SEARCH:       "tickets.json",
+	})
+	if err := svc.Init(search.USER_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	seats, _ := svc.Field("seats")
+	assert.Equal(t, search.NUMERIC_FIELD, seats.Type)
+	trial, _ := svc.Field("trial")
+	assert.Equal(t, search.BOOLEAN_FIELD, trial.Type)
+	team, _ := svc.Field("team")
+	assert.Equal(t, search.TEXT_FIELD, team.Type)
+
+	result, err := svc.Search("trial", "false")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "Nobody", result[0]["name"])
+}
+
+func TestValidateReportsUndeclaredFieldsAfterInit(t *testing.T) {
+	svc := newValidateService(`[{"_id": 1, "name": "Burgess England", "team": "Billing"}]`, orgsJson, ticketsJson)
+	if err := svc.Init(search.USER_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	result, err := svc.Validate()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if assert.Equal(t, 1, len(result.Problems)) {
+		assert.Equal(t, "team", result.Problems[0].Field)
+		assert.Equal(t, search.UNDECLARED_FIELD, result.Problems[0].Kind)
+	}
+}
@@ -0,0 +1,146 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// MatchMode sets how the value of a Condition is matched against a text or
+// keyword field. Other field types are always matched by value.
+type MatchMode string
+
+const (
+	// EXACT_MODE matches the whole value of the field, case included.
+	EXACT_MODE MatchMode = "exact"
+	// MATCH_MODE matches any of the words of the value, ignoring case and
+	// word endings. It is the default.
+	MATCH_MODE MatchMode = "match"
+	// FUZZY_MODE matches words within an edit distance of those of the value.
+	FUZZY_MODE MatchMode = "fuzzy"
+	// PREFIX_MODE matches fields starting with the value, ignoring case.
+	PREFIX_MODE MatchMode = "prefix"
+	// WILDCARD_MODE matches the whole value of the field against a pattern in
+	// which * stands for any characters and ? for any one character, ignoring
+	// case.
+	WILDCARD_MODE MatchMode = "wildcard"
+	// REGEX_MODE matches the whole value of the field against a regular
+	// expression, case included unless the expression starts with (?i).
+	REGEX_MODE MatchMode = "regex"
+)
+
+// MatchModes lists the available match modes.
+var MatchModes = []MatchMode{EXACT_MODE, MATCH_MODE, FUZZY_MODE, PREFIX_MODE, WILDCARD_MODE, REGEX_MODE}
+
+// DEFAULT_FUZZINESS is the edit distance used by FUZZY_MODE when none is
+// given, MAX_FUZZINESS the largest edit distance allowed.
+const (
+	DEFAULT_FUZZINESS = 1
+	MAX_FUZZINESS     = 2
+)
+
+// ParseMatchMode parses the name of a match mode, case insensitively.
+func ParseMatchMode(s string) (MatchMode, error) {
+	for _, m := range MatchModes {
+		if strings.EqualFold(s, string(m)) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown match mode %q, expected one of %s", s, strings.Join(MatchModeNames(), ", "))
+}
+
+// MatchModeNames returns the names of the available match modes.
+func MatchModeNames() []string {
+	names := make([]string, len(MatchModes))
+	for i, m := range MatchModes {
+		names[i] = string(m)
+	}
+	return names
+}
+
+// FieldMatchModes returns the match modes that can be used on field, which
+// is only MATCH_MODE for fields other than text and keyword fields.
+func (svc *Service) FieldMatchModes(field string) ([]MatchMode, error) {
+	f, err := svc.Field(field)
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != TEXT_FIELD && f.Type != KEYWORD_FIELD {
+		return []MatchMode{MATCH_MODE}, nil
+	}
+	return MatchModes, nil
+}
+
+// buildModeQuery returns a query matching value on field in the given mode.
+// Text fields are matched word by word in MATCH_MODE and FUZZY_MODE and on
+// their whole value otherwise, lower cased unless the mode is case sensitive.
+func buildModeQuery(field Field, value string, mode MatchMode, fuzziness int) (query.Query, error) {
+	if mode == "" || mode == MATCH_MODE {
+		return buildFieldQuery(field, value)
+	}
+	if field.Type != TEXT_FIELD && field.Type != KEYWORD_FIELD {
+		if mode == EXACT_MODE {
+			return buildFieldQuery(field, value)
+		}
+		return nil, fmt.Errorf("field %s is a %s field, %s matching is only supported on text and keyword fields", field.Name, field.Type, mode)
+	}
+
+	// Whole values of text fields are matched on their exact copy when case
+	// matters and on their lower cased sort copy when it does not.
+	exactName, foldedName := field.Name, field.Name
+	if field.Type == TEXT_FIELD {
+		exactName = EXACT_FIELD_PREFIX + field.Name
+		foldedName = SORT_FIELD_PREFIX + field.Name
+	}
+
+	switch mode {
+	case EXACT_MODE:
+		q := bleve.NewTermQuery(value)
+		q.SetField(exactName)
+		return q, nil
+	case FUZZY_MODE:
+		if fuzziness == 0 {
+			fuzziness = DEFAULT_FUZZINESS
+		}
+		if fuzziness < 0 || fuzziness > MAX_FUZZINESS {
+			return nil, fmt.Errorf("invalid edit distance %d, expected 1 to %d", fuzziness, MAX_FUZZINESS)
+		}
+		if field.Type == KEYWORD_FIELD {
+			q := bleve.NewFuzzyQuery(value)
+			q.SetField(field.Name)
+			q.SetFuzziness(fuzziness)
+			return q, nil
+		}
+		q := bleve.NewMatchQuery(value)
+		q.SetField(field.Name)
+		q.Analyzer = field.analyzer()
+		q.SetFuzziness(fuzziness)
+		return q, nil
+	case PREFIX_MODE:
+		if field.Type == TEXT_FIELD {
+			value = strings.ToLower(value)
+		}
+		q := bleve.NewPrefixQuery(value)
+		q.SetField(foldedName)
+		return q, nil
+	case WILDCARD_MODE:
+		if field.Type == TEXT_FIELD {
+			value = strings.ToLower(value)
+		}
+		q := bleve.NewWildcardQuery(value)
+		q.SetField(foldedName)
+		return q, nil
+	case REGEX_MODE:
+		if _, err := regexp.Compile(value); err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q for field %s: %v", value, field.Name, err)
+		}
+		q := bleve.NewRegexpQuery(value)
+		q.SetField(exactName)
+		return q, nil
+	}
+	_, err := ParseMatchMode(string(mode))
+	return nil, err
+}
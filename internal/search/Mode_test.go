@@ -0,0 +1,74 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestTicketFindMatchModes(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		name      string
+		condition search.Condition
+		expected  int
+	}{
+		{"match", search.Condition{Field: "subject", Value: "morocco", Mode: search.MATCH_MODE}, 1},
+		{"exact", search.Condition{Field: "subject", Value: "A Problem in Morocco", Mode: search.EXACT_MODE}, 1},
+		{"exact case", search.Condition{Field: "subject", Value: "a problem in morocco", Mode: search.EXACT_MODE}, 0},
+		{"exact word", search.Condition{Field: "subject", Value: "Morocco", Mode: search.EXACT_MODE}, 0},
+		{"exact tag", search.Condition{Field: "tags", Value: "New York", Mode: search.EXACT_MODE}, 1},
+		{"exact number", search.Condition{Field: "organization_id", Value: "1", Mode: search.EXACT_MODE}, 2},
+		{"fuzzy", search.Condition{Field: "subject", Value: "Marocco", Mode: search.FUZZY_MODE}, 1},
+		{"fuzzy distance", search.Condition{Field: "subject", Value: "Maroco", Mode: search.FUZZY_MODE}, 0},
+		{"fuzzy distance 2", search.Condition{Field: "subject", Value: "Maroco", Mode: search.FUZZY_MODE, Fuzziness: 2}, 1},
+		{"fuzzy keyword", search.Condition{Field: "_id", Value: "87db32c5-76a3-4069-954c-7d59c6c21de1", Mode: search.FUZZY_MODE}, 1},
+		{"prefix", search.Condition{Field: "subject", Value: "a cat", Mode: search.PREFIX_MODE}, 1},
+		{"prefix tag", search.Condition{Field: "tags", Value: "new", Mode: search.PREFIX_MODE}, 1},
+		{"prefix keyword", search.Condition{Field: "_id", Value: "2217", Mode: search.PREFIX_MODE}, 1},
+		{"wildcard", search.Condition{Field: "subject", Value: "a * in ??ngary", Mode: search.WILDCARD_MODE}, 1},
+		{"regex", search.Condition{Field: "subject", Value: "A (Problem|Catastrophe) in .*", Mode: search.REGEX_MODE}, 2},
+		{"regex case", search.Condition{Field: "subject", Value: "a problem.*", Mode: search.REGEX_MODE}, 0},
+		{"regex ignore case", search.Condition{Field: "subject", Value: "(?i)a problem.*", Mode: search.REGEX_MODE}, 1},
+	}
+
+	for _, test := range tests {
+		results, err := svc.Find(search.Request{Query: test.condition})
+		if err != nil {
+			assert.Fail(t, err.Error(), test.name)
+			continue
+		}
+		assert.Equal(t, uint64(test.expected), results.Total, test.name)
+	}
+
+	for _, c := range []search.Condition{
+		{Field: "organization_id", Value: "1", Mode: search.PREFIX_MODE},
+		{Field: "subject", Value: "Morocco", Mode: search.FUZZY_MODE, Fuzziness: 3},
+		{Field: "subject", Value: "(", Mode: search.REGEX_MODE},
+		{Field: "subject", Value: "Morocco", Mode: "soundex"},
+	} {
+		_, err = svc.Find(search.Request{Query: c})
+		assert.Error(t, err)
+	}
+}
+
+func TestParseMatchMode(t *testing.T) {
+	mode, err := search.ParseMatchMode("Fuzzy")
+	assert.NoError(t, err)
+	assert.Equal(t, search.FUZZY_MODE, mode)
+
+	_, err = search.ParseMatchMode("soundex")
+	assert.Error(t, err)
+}
@@ -0,0 +1,331 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	stringToken
+	minusToken
+	lparenToken
+	rparenToken
+	andToken
+	orToken
+	notToken
+	endToken
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+// ParseError reports a query string that could not be parsed, along with the
+// position of the offending token.
+type ParseError struct {
+	Input string
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s at position %d", e.Msg, e.column()+1)
+	}
+	return fmt.Sprintf("%s at position %d near %q", e.Msg, e.column()+1, e.Token)
+}
+
+// Pointer returns the query string with a caret marking the offending token
+// on the line below it.
+func (e *ParseError) Pointer() string {
+	return e.Input + "\n" + strings.Repeat(" ", e.column()) + "^"
+}
+
+// column is the position of the offending token in characters rather than
+// bytes.
+func (e *ParseError) column() int {
+	return utf8.RuneCountInString(e.Input[:e.Pos])
+}
+
+// ParseQuery parses a query string into a Query on the fields of the current
+// search type. The query string is made up of field:value conditions, all of
+// which must match unless separated by OR, e.g.
+//
+//	status:pending priority:(high OR urgent) -tags:Ohio created_at:>2016-05-01
+//
+// Conditions are negated with a leading "-" or NOT and grouped in parentheses.
+// A group after a field applies the field to each of its values. Values with
+// spaces are quoted, and an empty quoted value matches records where the
+// field is empty. Values are parsed as for Search, so ranges are allowed on
+// numeric and date fields.
+func (svc *Service) ParseQuery(input string) (Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		input:  input,
+		tokens: tokens,
+		svc:    svc,
+	}
+	return p.parse()
+}
+
+func lex(input string) ([]token, error) {
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(input) {
+		c, size := utf8.DecodeRuneInString(input[i:])
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c == '(':
+			tokens = append(tokens, token{kind: lparenToken, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: rparenToken, text: ")", pos: i})
+			i++
+		case c == '-' && i+1 < len(input) && !unicode.IsSpace(runeAt(input, i+1)) && runeAt(input, i+1) != ')':
+			tokens = append(tokens, token{kind: minusToken, text: "-", pos: i})
+			i++
+		case c == '"':
+			t, end, err := lexString(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i = end
+		default:
+			start := i
+			for i < len(input) && !isDelimiter(runeAt(input, i)) {
+				_, size := utf8.DecodeRuneInString(input[i:])
+				i += size
+			}
+			text := input[start:i]
+			t := token{kind: wordToken, text: text, value: text, pos: start}
+			switch text {
+			case "AND":
+				t.kind = andToken
+			case "OR":
+				t.kind = orToken
+			case "NOT":
+				t.kind = notToken
+			}
+			tokens = append(tokens, t)
+		}
+	}
+	return append(tokens, token{kind: endToken, pos: len(input)}), nil
+}
+
+func runeAt(input string, i int) rune {
+	c, _ := utf8.DecodeRuneInString(input[i:])
+	return c
+}
+
+func isDelimiter(c rune) bool {
+	return unicode.IsSpace(c) || c == '(' || c == ')' || c == '"'
+}
+
+func lexString(input string, start int) (token, int, error) {
+	var value strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			if i+1 < len(input) {
+				i++
+				value.WriteByte(input[i])
+			}
+		case '"':
+			return token{kind: stringToken, text: input[start : i+1], value: value.String(), pos: start}, i + 1, nil
+		default:
+			value.WriteByte(input[i])
+		}
+	}
+	return token{}, 0, &ParseError{Input: input, Pos: start, Token: input[start:], Msg: "unterminated quoted value"}
+}
+
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+	svc    *Service
+}
+
+func (p *parser) parse() (Query, error) {
+	if p.peek().kind == endToken {
+		return nil, p.errorf(p.peek(), "empty query")
+	}
+	q, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != endToken {
+		if t.kind == rparenToken {
+			return nil, p.errorf(t, "unexpected closing parenthesis")
+		}
+		return nil, p.errorf(t, "unexpected token")
+	}
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != endToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &ParseError{Input: p.input, Pos: t.pos, Token: t.text, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseOr parses conditions separated by OR. Within a group following a
+// field, field names the field its bare values apply to.
+func (p *parser) parseOr(field string) (Query, error) {
+	q, err := p.parseAnd(field)
+	if err != nil {
+		return nil, err
+	}
+	queries := Or{q}
+	for p.peek().kind == orToken {
+		p.next()
+		q, err := p.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	if len(queries) == 1 {
+		return queries[0], nil
+	}
+	return queries, nil
+}
+
+func (p *parser) parseAnd(field string) (Query, error) {
+	q, err := p.parseUnary(field)
+	if err != nil {
+		return nil, err
+	}
+	queries := And{q}
+	for {
+		switch p.peek().kind {
+		case orToken, rparenToken, endToken:
+			if len(queries) == 1 {
+				return queries[0], nil
+			}
+			return queries, nil
+		case andToken:
+			p.next()
+		}
+		q, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+}
+
+func (p *parser) parseUnary(field string) (Query, error) {
+	switch p.peek().kind {
+	case minusToken, notToken:
+		p.next()
+		q, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Query: q}, nil
+	}
+	return p.parsePrimary(field)
+}
+
+func (p *parser) parsePrimary(field string) (Query, error) {
+	t := p.next()
+	switch t.kind {
+	case lparenToken:
+		return p.parseGroup(t, field)
+	case stringToken:
+		if field == "" {
+			return nil, p.errorf(t, "expected field:value")
+		}
+		return p.condition(t, field, t.value)
+	case wordToken:
+		i := strings.Index(t.value, ":")
+		if i < 0 {
+			if field == "" {
+				return nil, p.errorf(t, "expected field:value")
+			}
+			return p.condition(t, field, t.value)
+		}
+		if i == 0 {
+			return nil, p.errorf(t, "missing field name")
+		}
+		if field != "" {
+			return nil, p.errorf(t, "field %s cannot be nested in a group for field %s", t.value[:i], field)
+		}
+		return p.parseFieldValue(t, t.value[:i], t.value[i+1:])
+	case endToken:
+		return nil, p.errorf(t, "unexpected end of query")
+	}
+	return nil, p.errorf(t, "unexpected token")
+}
+
+func (p *parser) parseGroup(open token, field string) (Query, error) {
+	if p.peek().kind == rparenToken {
+		return nil, p.errorf(p.peek(), "empty group")
+	}
+	q, err := p.parseOr(field)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != rparenToken {
+		return nil, p.errorf(open, "missing closing parenthesis")
+	}
+	return q, nil
+}
+
+// parseFieldValue parses the value following field:, which is either part of
+// the same word, a quoted value or a group of values.
+func (p *parser) parseFieldValue(t token, field string, value string) (Query, error) {
+	if err := p.svc.ValidateField(field); err != nil {
+		return nil, p.errorf(t, "unknown field %s, expected one of %s", field, strings.Join(p.svc.ListFields(), ", "))
+	}
+	if value != "" {
+		return p.condition(t, field, value)
+	}
+
+	v := p.next()
+	switch v.kind {
+	case stringToken:
+		return p.condition(v, field, v.value)
+	case lparenToken:
+		return p.parseGroup(v, field)
+	}
+	return nil, p.errorf(t, "missing value for field %s", field)
+}
+
+// condition builds the condition for field and value, checking that the value
+// suits the field type so that errors point at the offending token.
+func (p *parser) condition(t token, field string, value string) (Query, error) {
+	c := Condition{Field: field, Value: value}
+	if t.kind == stringToken && value == "" {
+		c = Condition{Field: field, Empty: true}
+	}
+	if _, err := c.build(p.svc); err != nil {
+		return nil, p.errorf(t, "%v", err)
+	}
+	return c, nil
+}
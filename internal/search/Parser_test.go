@@ -0,0 +1,100 @@
+package search_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestTicketParseQuery(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{`status:closed priority:(normal OR urgent) -tags:Ohio created_at:>2016-05-01`, 1},
+		{`status:closed OR priority:urgent`, 2},
+		{`type:problem AND NOT via:web`, 1},
+		{`priority:(normal OR urgent) -(status:solved OR via:voice)`, 1},
+		{`tags:"New York"`, 1},
+		{`subject:"Catastrophe in Hungary"`, 1},
+		{`assignee_id:""`, 0},
+		{`-assignee_id:""`, 2},
+		{`due_at:2016-08-06..2016-08-19 has_incidents:true`, 2},
+		{`_id:87db32c5-76a3-4069-954c-7d59c6c21de0`, 1},
+	}
+
+	for _, test := range tests {
+		q, err := svc.ParseQuery(test.query)
+		if err != nil {
+			assert.Fail(t, err.Error(), test.query)
+			continue
+		}
+		results, err := svc.Find(search.Request{Query: q})
+		if err != nil {
+			assert.Fail(t, err.Error(), test.query)
+			continue
+		}
+		assert.Equal(t, uint64(test.expected), results.Total, test.query)
+	}
+}
+
+func TestTicketParseQueryErrors(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		query string
+		pos   int
+		token string
+	}{
+		{`status:pending prio:high`, 15, "prio:high"},
+		{`status:pending (priority:high`, 15, "("},
+		{`priority:high)`, 13, ")"},
+		{`pending`, 0, "pending"},
+		{`subject:"Catastrophe`, 8, `"Catastrophe`},
+		{`has_incidents:maybe`, 0, "has_incidents:maybe"},
+		{`status:open organization_id:(1 OR one)`, 34, "one"},
+		{`status:open OR`, 14, ""},
+		{``, 0, ""},
+	}
+
+	for _, test := range tests {
+		_, err := svc.ParseQuery(test.query)
+		var parseErr *search.ParseError
+		if !errors.As(err, &parseErr) {
+			assert.Fail(t, "expected a parse error", test.query)
+			continue
+		}
+		assert.Equal(t, test.pos, parseErr.Pos, test.query)
+		assert.Equal(t, test.token, parseErr.Token, test.query)
+	}
+
+	_, err = svc.ParseQuery(`status:pending prio:high`)
+	var parseErr *search.ParseError
+	if errors.As(err, &parseErr) {
+		assert.Equal(t, "status:pending prio:high\n               ^", parseErr.Pointer())
+	}
+}
@@ -0,0 +1,333 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"gopkg.in/yaml.v2"
+)
+
+// INDEX_FORMAT_VERSION is increased whenever what is kept in a persistent
+// index changes, so that the indexes built by older versions are rebuilt.
+const INDEX_FORMAT_VERSION = 2
+
+// The files of a persistent index. The manifest is written last, so an index
+// without one is incomplete.
+const (
+	MANIFEST_FILE_NAME = "manifest.json"
+	BLEVE_DIR_NAME     = "index.bleve"
+)
+
+// indexManifest describes a persistent index: the hashes of the contents of
+// the data files it was built from, and the fields inferred from them.
+type indexManifest struct {
+	Version int              `json:"version"`
+	Built   time.Time        `json:"built"`
+	Hashes  map[Type]string  `json:"hashes"`
+	Fields  map[Type][]Field `json:"fields"`
+}
+
+// IndexStatus describes the persistent index of the data files. Built is zero
+// when none has been built, and Changed lists the types whose data files have
+// changed since it was.
+type IndexStatus struct {
+	Dir     string
+	Built   time.Time
+	Changed []Type
+}
+
+// Exists reports whether the index has been built.
+func (s *IndexStatus) Exists() bool {
+	return !s.Built.IsZero()
+}
+
+// Current reports whether the index has been built from the data files as
+// they are now.
+func (s *IndexStatus) Current() bool {
+	return s.Exists() && len(s.Changed) == 0
+}
+
+// PersistIndex makes Init keep the index in a directory under dir, to be
+// reused by later services as long as the schema and the contents of the
+// data files are unchanged. Each schema and set of data files has a directory
+// of its own.
+func (svc *Service) PersistIndex(dir string) {
+	svc.indexDir = dir
+}
+
+// HasPersistentIndex reports whether the data files have been indexed under
+// dir, whether or not they have changed since.
+func (svc *Service) HasPersistentIndex(dir string) bool {
+	loc, err := svc.indexLocation(dir)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(loc, MANIFEST_FILE_NAME))
+	return err == nil
+}
+
+// LoadIndexedFields adds the fields that the schema does not declare from the
+// manifest of the persistent index of the data files under dir, without
+// reading the data files. The fields are those found when the index was
+// built, whether or not the data files have changed since. It reports whether
+// there is an index to read them from.
+func (svc *Service) LoadIndexedFields(dir string) (bool, error) {
+	loc, err := svc.indexLocation(dir)
+	if err != nil {
+		return false, err
+	}
+	m, err := readManifest(loc)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	schema, err := svc.declared.withFields(m.Fields)
+	if err != nil {
+		return false, err
+	}
+	svc.schema = schema
+	return true, nil
+}
+
+// IndexStatus describes the index of the data files under dir.
+func (svc *Service) IndexStatus(dir string) (*IndexStatus, error) {
+	loc, err := svc.indexLocation(dir)
+	if err != nil {
+		return nil, err
+	}
+	status := &IndexStatus{Dir: loc}
+	m, err := readManifest(loc)
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := svc.hashFiles()
+	if err != nil {
+		return nil, err
+	}
+	status.Built = m.Built
+	for _, t := range svc.declared.Types {
+		if m.Version != INDEX_FORMAT_VERSION || m.Hashes[t.Name] != hashes[t.Name] {
+			status.Changed = append(status.Changed, t.Name)
+		}
+	}
+	return status, nil
+}
+
+// Reindex builds the persistent index afresh, whether or not the data files
+// have changed, and loads it for the current search type.
+func (svc *Service) Reindex() error {
+	if svc.indexDir == "" {
+		return fmt.Errorf("no index directory given")
+	}
+	if err := svc.Close(); err != nil {
+		return err
+	}
+	return svc.openPersistentIndex(true)
+}
+
+// ClearIndexes removes the persistent indexes under dir. Only the directories
+// holding an index are removed, and dir itself once it is empty.
+func ClearIndexes(dir string) (int, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		loc := filepath.Join(dir, e.Name())
+		if !e.IsDir() || !isIndexDir(loc) {
+			continue
+		}
+		if err := os.RemoveAll(loc); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	// dir is kept when it holds anything else.
+	os.Remove(dir)
+	return removed, nil
+}
+
+// isIndexDir reports whether dir holds a persistent index, complete or not.
+func isIndexDir(dir string) bool {
+	for _, name := range []string{MANIFEST_FILE_NAME, BLEVE_DIR_NAME} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// indexLocation returns the directory under dir of the index of the data
+// files, which is named after a hash of the schema and the paths of the
+// files.
+func (svc *Service) indexLocation(dir string) (string, error) {
+	schema, err := yaml.Marshal(svc.declared)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s", INDEX_FORMAT_VERSION, schema)
+	for _, t := range svc.declared.Types {
+		path, err := filepath.Abs(svc.files[t.Name])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s=%s\n", t.Name, path)
+	}
+	return filepath.Join(dir, hex.EncodeToString(h.Sum(nil))[:16]), nil
+}
+
+// hashFiles returns the hashes of the contents of the data files.
+func (svc *Service) hashFiles() (map[Type]string, error) {
+	hashes := make(map[Type]string, len(svc.declared.Types))
+	for _, t := range svc.declared.Types {
+		f, err := svc.openFile(t.Name, svc.files[t.Name])
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, parseError(t.Name, svc.files[t.Name], err)
+		}
+		hashes[t.Name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes, nil
+}
+
+// openPersistentIndex opens the persistent index of the data files, building
+// it when there is none, it is out of date or rebuild is set. An index that
+// cannot be opened is rebuilt too.
+func (svc *Service) openPersistentIndex(rebuild bool) error {
+	loc, err := svc.indexLocation(svc.indexDir)
+	if err != nil {
+		return err
+	}
+	hashes, err := svc.hashFiles()
+	if err != nil {
+		return err
+	}
+	if !rebuild {
+		m, err := readManifest(loc)
+		if err == nil && m.current(hashes) && svc.openIndex(loc, m) == nil {
+			return nil
+		}
+	}
+	m, err := svc.writeIndex(loc, hashes)
+	if err != nil {
+		return fmt.Errorf("cannot write index to %s: %v", loc, err)
+	}
+	return svc.openIndex(loc, m)
+}
+
+// current reports whether the index was built by this version from data
+// files with hashes.
+func (m *indexManifest) current(hashes map[Type]string) bool {
+	if m.Version != INDEX_FORMAT_VERSION || len(m.Hashes) != len(hashes) {
+		return false
+	}
+	for t, h := range hashes {
+		if m.Hashes[t] != h {
+			return false
+		}
+	}
+	return true
+}
+
+func readManifest(loc string) (*indexManifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(loc, MANIFEST_FILE_NAME))
+	if err != nil {
+		return nil, err
+	}
+	var m indexManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// writeIndex builds the index of the data files in loc, replacing any index
+// there, and returns its manifest. The index is closed once it is written, to
+// be opened with openIndex.
+func (svc *Service) writeIndex(loc string, hashes map[Type]string) (*indexManifest, error) {
+	if err := os.RemoveAll(loc); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(loc, 0755); err != nil {
+		return nil, err
+	}
+	err := svc.buildIndex(newDiskIndex(filepath.Join(loc, BLEVE_DIR_NAME)), svc.declared.allTypes())
+	if err != nil {
+		return nil, err
+	}
+	// Closing the index waits for it to be written in full.
+	err = svc.index.Close()
+	svc.index = nil
+	if err != nil {
+		return nil, err
+	}
+
+	m := indexManifest{
+		Version: INDEX_FORMAT_VERSION,
+		Built:   time.Now(),
+		Hashes:  hashes,
+		Fields:  make(map[Type][]Field),
+	}
+	for _, t := range svc.schema.Types {
+		for _, f := range t.Fields {
+			if f.Inferred {
+				m.Fields[t.Name] = append(m.Fields[t.Name], f)
+			}
+		}
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(loc, MANIFEST_FILE_NAME+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, filepath.Join(loc, MANIFEST_FILE_NAME)); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// openIndex opens the index in loc described by m. The mapping kept in the
+// index lacks the fields inferred while it was built, which are added to the
+// schema from m instead, but it is only needed to index documents, as the
+// queries name the analyzers of the fields they search.
+func (svc *Service) openIndex(loc string, m *indexManifest) error {
+	schema, err := svc.declared.withFields(m.Fields)
+	if err != nil {
+		return err
+	}
+	// The index is opened read only so that it can be searched by more than
+	// one process at a time.
+	index, err := bleve.OpenUsing(filepath.Join(loc, BLEVE_DIR_NAME), map[string]interface{}{"read_only": true})
+	if err != nil {
+		return err
+	}
+	svc.index = index
+	svc.schema = schema
+	return nil
+}
@@ -0,0 +1,156 @@
+package search_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestPersistentIndexIsReusedUntilDataChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zen-index")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	users := `[{"_id": 1, "name": "Burgess England", "organization_id": 1, "team": "Billing"}]`
+	svc := newValidateService(users, orgsJson, ticketsJson)
+	assert.False(t, svc.HasPersistentIndex(dir))
+	status, err := svc.IndexStatus(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.False(t, status.Exists())
+
+	svc.PersistIndex(dir)
+	if err := svc.Init(search.TICKET_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.True(t, svc.HasPersistentIndex(dir))
+	status, err = svc.IndexStatus(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.True(t, status.Current())
+	built := status.Built
+
+	// A service for the same data opens the index rather than building it.
+	reused := newValidateService(users, orgsJson, ticketsJson)
+	reused.PersistIndex(dir)
+	if err := reused.Init(search.TICKET_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	status, err = reused.IndexStatus(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.True(t, built.Equal(status.Built))
+	assert.Equal(t, svc.ListFields(), reused.ListFields())
+	result, err := reused.Search("submitter.team", "billing")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "Burgess England", result[0]["submitter"])
+	ticket, err := reused.Get("2217c7dc-7371-4401-8738-0a8a8aedc08d")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, "Limozen", ticket["organization"].(map[string]interface{})["name"])
+
+	// A change to any data file rebuilds it.
+	changed := newValidateService(`[{"_id": 1, "name": "Burgess Scotland", "organization_id": 1}]`, orgsJson, ticketsJson)
+	status, err = changed.IndexStatus(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.True(t, status.Exists())
+	assert.Equal(t, []search.Type{search.USER_SEARCH}, status.Changed)
+
+	changed.PersistIndex(dir)
+	if err := changed.Init(search.TICKET_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Error(t, changed.ValidateField("submitter.team"))
+	result, err = changed.Search("submitter.name", "scotland")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 2, len(result))
+	status, err = changed.IndexStatus(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.True(t, status.Current())
+
+	n, err := search.ClearIndexes(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 1, n)
+	assert.False(t, changed.HasPersistentIndex(dir))
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadIndexedFieldsReadsNoData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zen-index")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// Opening any file fails the test, as the mock expects none to be.
+	svc := search.NewWithFiles(&mockFileService{}, validateFiles)
+	if err := svc.SetSearchType(search.USER_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	ok, err := svc.LoadIndexedFields(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.False(t, ok)
+	assert.Equal(t, userFields, svc.ListFields()[:len(userFields)])
+	assert.NotContains(t, svc.ListFields(), "team")
+
+	built := newValidateService(`[{"_id": 1, "name": "Burgess England", "team": "Billing"}]`, orgsJson, ticketsJson)
+	built.PersistIndex(dir)
+	if err := built.Init(search.USER_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	built.Close()
+
+	ok, err = svc.LoadIndexedFields(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.True(t, ok)
+	assert.Contains(t, svc.ListFields(), "team")
+}
+
+func TestClearIndexesKeepsOtherFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zen-index")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(dir+"/notes.txt", []byte("keep"), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := os.Mkdir(dir+"/other", 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	n, err := search.ClearIndexes(dir)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 0, n)
+	_, err = os.Stat(dir + "/notes.txt")
+	assert.NoError(t, err)
+	_, err = os.Stat(dir + "/other")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,125 @@
+package search
+
+import (
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// Query selects the records to search for. Queries are built from Conditions
+// on single fields, composed with And, Or and Not.
+type Query interface {
+	build(svc *Service) (query.Query, error)
+}
+
+// Condition matches the records whose Field has Value, falls within Range or,
+// when Empty is set, is missing or blank. When Values is set instead of Value
+// the records having any of them match, or those having all of them when All
+// is set, which for array fields such as tags means having each of them among
+// their elements. Only one of Value, Values, Range and Empty is used, in that
+// order of precedence: Empty, then Range, then Values. Mode sets how values
+// are matched, MATCH_MODE when not set, and Fuzziness the edit distance
+// allowed in FUZZY_MODE.
+type Condition struct {
+	Field     string
+	Value     string
+	Values    []string
+	All       bool
+	Range     *Range
+	Empty     bool
+	Mode      MatchMode
+	Fuzziness int
+}
+
+// And matches the records matching all of its queries, or every record when
+// it is empty.
+type And []Query
+
+// Or matches the records matching any of its queries, or no record when it is
+// empty.
+type Or []Query
+
+// Not matches the records that do not match Query.
+type Not struct {
+	Query Query
+}
+
+func (c Condition) build(svc *Service) (query.Query, error) {
+	field, err := svc.Field(c.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Empty {
+		return buildEmptyQuery(field), nil
+	}
+
+	if c.Range != nil {
+		return buildRangeQuery(field, *c.Range)
+	}
+
+	if len(c.Values) > 0 {
+		queries := make([]query.Query, len(c.Values))
+		for i, v := range c.Values {
+			single := c
+			single.Value, single.Values = v, nil
+			if queries[i], err = single.build(svc); err != nil {
+				return nil, err
+			}
+		}
+		if c.All {
+			return bleve.NewConjunctionQuery(queries...), nil
+		}
+		return bleve.NewDisjunctionQuery(queries...), nil
+	}
+
+	if field.Type == NUMERIC_FIELD || field.Type == DATETIME_FIELD {
+		if r, ok := ParseRange(c.Value); ok {
+			return buildRangeQuery(field, r)
+		}
+	}
+	return buildModeQuery(field, c.Value, c.Mode, c.Fuzziness)
+}
+
+func (a And) build(svc *Service) (query.Query, error) {
+	if len(a) == 0 {
+		return bleve.NewMatchAllQuery(), nil
+	}
+	queries, err := buildQueries(svc, a)
+	if err != nil {
+		return nil, err
+	}
+	return bleve.NewConjunctionQuery(queries...), nil
+}
+
+func (o Or) build(svc *Service) (query.Query, error) {
+	if len(o) == 0 {
+		return bleve.NewMatchNoneQuery(), nil
+	}
+	queries, err := buildQueries(svc, o)
+	if err != nil {
+		return nil, err
+	}
+	return bleve.NewDisjunctionQuery(queries...), nil
+}
+
+func (n Not) build(svc *Service) (query.Query, error) {
+	q, err := n.Query.build(svc)
+	if err != nil {
+		return nil, err
+	}
+	notQuery := bleve.NewBooleanQuery()
+	notQuery.AddMust(bleve.NewMatchAllQuery())
+	notQuery.AddMustNot(q)
+	return notQuery, nil
+}
+
+func buildQueries(svc *Service, queries []Query) ([]query.Query, error) {
+	built := make([]query.Query, len(queries))
+	for i, q := range queries {
+		var err error
+		if built[i], err = q.build(svc); err != nil {
+			return nil, err
+		}
+	}
+	return built, nil
+}
@@ -0,0 +1,124 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestTicketFindBooleanQueries(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		name     string
+		query    search.Query
+		expected int
+	}{
+		{"and", search.And{search.Condition{Field: "type", Value: "problem"}, search.Condition{Field: "priority", Value: "urgent"}}, 1},
+		{"and none", search.And{search.Condition{Field: "status", Value: "closed"}, search.Condition{Field: "priority", Value: "urgent"}}, 0},
+		{"or", search.Or{search.Condition{Field: "status", Value: "closed"}, search.Condition{Field: "priority", Value: "urgent"}}, 2},
+		{"not", search.Not{Query: search.Condition{Field: "via", Value: "web"}}, 1},
+		{"and not", search.And{search.Condition{Field: "organization_id", Value: "1"}, search.Not{Query: search.Condition{Field: "status", Value: "solved"}}}, 1},
+		{"nested", search.And{search.Condition{Field: "has_incidents", Value: "true"}, search.Or{search.Condition{Field: "via", Value: "voice"}, search.Condition{Field: "tags", Value: "Ohio"}}}, 1},
+		{"empty and", search.And{}, 2},
+		{"empty or", search.Or{}, 0},
+	}
+
+	for _, test := range tests {
+		results, err := svc.Find(search.Request{Query: test.query})
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		assert.Equal(t, uint64(test.expected), results.Total, test.name)
+		assert.Equal(t, test.expected, len(results.Hits), test.name)
+	}
+
+	_, err = svc.Find(search.Request{Query: search.Or{search.Condition{Field: "priority", Value: "high"}, search.Condition{Field: "assignee", Value: "1"}}})
+	assert.Error(t, err)
+}
+
+func TestTicketFindManyValues(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		name      string
+		condition search.Condition
+		expected  int
+	}{
+		{"any of", search.Condition{Field: "tags", Values: []string{"Texas", "Ohio"}}, 1},
+		{"any of both", search.Condition{Field: "tags", Values: []string{"Texas", "Minnesota"}}, 2},
+		{"all of", search.Condition{Field: "tags", Values: []string{"Texas", "Nevada"}, All: true}, 1},
+		{"all of none", search.Condition{Field: "tags", Values: []string{"Texas", "Minnesota"}, All: true}, 0},
+		{"partial", search.Condition{Field: "tags", Values: []string{"new"}}, 1},
+		{"exact element", search.Condition{Field: "tags", Values: []string{"New York", "New Jersey"}, All: true, Mode: search.EXACT_MODE}, 1},
+		{"exact element partial", search.Condition{Field: "tags", Values: []string{"New"}, Mode: search.EXACT_MODE}, 0},
+		{"exact element words", search.Condition{Field: "tags", Values: []string{"New Minnesota"}, Mode: search.EXACT_MODE}, 0},
+		{"status any of", search.Condition{Field: "status", Values: []string{"closed", "solved"}}, 2},
+		{"number any of", search.Condition{Field: "organization_id", Values: []string{"1", "2"}}, 2},
+	}
+
+	for _, test := range tests {
+		results, err := svc.Find(search.Request{Query: test.condition})
+		if err != nil {
+			assert.Fail(t, err.Error(), test.name)
+			continue
+		}
+		assert.Equal(t, uint64(test.expected), results.Total, test.name)
+	}
+}
+
+func TestOrganizationFindDomainNames(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.ORGANIZATION_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		name      string
+		condition search.Condition
+		expected  int
+	}{
+		{"exact", search.Condition{Field: "domain_names", Value: "suremax.com", Mode: search.EXACT_MODE}, 1},
+		{"exact partial", search.Condition{Field: "domain_names", Value: "suremax", Mode: search.EXACT_MODE}, 0},
+		{"all of", search.Condition{Field: "domain_names", Values: []string{"suremax.com", "fishland.com"}, All: true, Mode: search.EXACT_MODE}, 1},
+		{"all of missing", search.Condition{Field: "domain_names", Values: []string{"suremax.com", "example.com"}, All: true, Mode: search.EXACT_MODE}, 0},
+		{"prefix", search.Condition{Field: "domain_names", Value: "rodeo", Mode: search.PREFIX_MODE}, 1},
+	}
+
+	for _, test := range tests {
+		results, err := svc.Find(search.Request{Query: test.condition})
+		if err != nil {
+			assert.Fail(t, err.Error(), test.name)
+			continue
+		}
+		assert.Equal(t, uint64(test.expected), results.Total, test.name)
+	}
+}
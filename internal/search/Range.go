@@ -0,0 +1,117 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// RANGE_SEPARATOR separates the bounds of a closed range, e.g. 101..110.
+const RANGE_SEPARATOR = ".."
+
+// Range bounds the values of a numeric or date field. Leaving Min or Max empty
+// leaves that end of the range open.
+type Range struct {
+	Min          string
+	Max          string
+	MinInclusive bool
+	MaxInclusive bool
+}
+
+// ParseRange parses a range expression. The supported forms are a comparison
+// such as ">=2016-07-01", "<2014-01-01", ">101" or "<=110", and a closed
+// range with inclusive bounds such as "101..110". The second return value
+// reports whether expr is a range expression at all.
+func ParseRange(expr string) (Range, bool) {
+	expr = strings.TrimSpace(expr)
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(expr, op) {
+			continue
+		}
+		bound := strings.TrimSpace(strings.TrimPrefix(expr, op))
+		if bound == "" {
+			return Range{}, false
+		}
+		switch op {
+		case ">=":
+			return Range{Min: bound, MinInclusive: true}, true
+		case ">":
+			return Range{Min: bound}, true
+		case "<=":
+			return Range{Max: bound, MaxInclusive: true}, true
+		default:
+			return Range{Max: bound}, true
+		}
+	}
+
+	if i := strings.Index(expr, RANGE_SEPARATOR); i > 0 {
+		min := strings.TrimSpace(expr[:i])
+		max := strings.TrimSpace(expr[i+len(RANGE_SEPARATOR):])
+		if max != "" {
+			return Range{Min: min, Max: max, MinInclusive: true, MaxInclusive: true}, true
+		}
+	}
+	return Range{}, false
+}
+
+func buildRangeQuery(field Field, r Range) (query.Query, error) {
+	if r.Min == "" && r.Max == "" {
+		return nil, fmt.Errorf("range for field %s needs at least one bound", field.Name)
+	}
+
+	switch field.Type {
+	case NUMERIC_FIELD:
+		var min, max *float64
+		if r.Min != "" {
+			n, err := strconv.ParseFloat(r.Min, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q for field %s", r.Min, field.Name)
+			}
+			min = &n
+		}
+		if r.Max != "" {
+			n, err := strconv.ParseFloat(r.Max, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q for field %s", r.Max, field.Name)
+			}
+			max = &n
+		}
+		minInclusive, maxInclusive := r.MinInclusive, r.MaxInclusive
+		q := bleve.NewNumericRangeInclusiveQuery(min, max, &minInclusive, &maxInclusive)
+		q.SetField(field.Name)
+		return q, nil
+	case DATETIME_FIELD:
+		var start, end time.Time
+		startInclusive, endInclusive := r.MinInclusive, r.MaxInclusive
+		if r.Min != "" {
+			from, to, err := parseDateTime(r.Min)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q for field %s: %v", r.Min, field.Name, err)
+			}
+			// A whole day is excluded by starting from the following day.
+			start = from
+			if !startInclusive && !to.Equal(from) {
+				start, startInclusive = to, true
+			}
+		}
+		if r.Max != "" {
+			from, to, err := parseDateTime(r.Max)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q for field %s: %v", r.Max, field.Name, err)
+			}
+			// A whole day is included by ending before the following day.
+			end = from
+			if endInclusive && !to.Equal(from) {
+				end, endInclusive = to, false
+			}
+		}
+		q := bleve.NewDateRangeInclusiveQuery(start, end, &startInclusive, &endInclusive)
+		q.SetField(field.Name)
+		return q, nil
+	}
+	return nil, fmt.Errorf("field %s is a %s field, ranges are only supported on numeric and date fields", field.Name, field.Type)
+}
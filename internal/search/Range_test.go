@@ -0,0 +1,32 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected search.Range
+		ok       bool
+	}{
+		{">=2016-07-01", search.Range{Min: "2016-07-01", MinInclusive: true}, true},
+		{">101", search.Range{Min: "101"}, true},
+		{"<= 110", search.Range{Max: "110", MaxInclusive: true}, true},
+		{"<2014-01-01", search.Range{Max: "2014-01-01"}, true},
+		{"101..110", search.Range{Min: "101", Max: "110", MinInclusive: true, MaxInclusive: true}, true},
+		{"101", search.Range{}, false},
+		{">=", search.Range{}, false},
+		{"101..", search.Range{}, false},
+		{"2016-04-28T11:19:34 -10:00", search.Range{}, false},
+	}
+
+	for _, test := range tests {
+		r, ok := search.ParseRange(test.expr)
+		assert.Equal(t, test.ok, ok, test.expr)
+		assert.Equal(t, test.expected, r, test.expr)
+	}
+}
@@ -0,0 +1,369 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// record is a record of an entity type as it is held for search results.
+// Values holds the value of each of its fields that is not missing: text as a
+// string, numbers as json.Number, booleans as bool and lists as
+// []interface{}. Related holds the record of each relation to one record that
+// exists, and Lists the records of each relation to many.
+type record struct {
+	entity  *EntityType
+	values  map[string]interface{}
+	related map[string]*record
+	lists   map[string][]*record
+}
+
+// id returns the primary key of the record.
+func (r *record) id() string {
+	return idString(r.values[ID_FIELD_NAME])
+}
+
+// newRecord converts the values read from a data file to the types of the
+// fields of t. Values of CSV files are strings, the elements of lists being
+// separated by CSV_LIST_SEPARATOR. Fields that t does not have are left out.
+func newRecord(t *EntityType, raw map[string]interface{}, fromCSV bool) (*record, error) {
+	r := &record{entity: t, values: make(map[string]interface{}, len(t.Fields))}
+	for _, f := range t.Fields {
+		v, err := convertValue(f, raw[f.Name], fromCSV)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		if v != nil {
+			r.values[f.Name] = v
+		}
+	}
+	return r, nil
+}
+
+// convertValue converts a value read from a data file to the type of field,
+// returning nil for a missing value. Numbers may be quoted, and in CSV files
+// so may booleans. The values of inferred fields may be of mixed types, so
+// lists of them may also be single values, and text numbers or booleans.
+func convertValue(field Field, value interface{}, fromCSV bool) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if !field.Array {
+		return convertScalar(field, value, fromCSV)
+	}
+
+	var elems []interface{}
+	if _, ok := value.([]interface{}); !ok && field.Inferred && !fromCSV {
+		value = []interface{}{value}
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		elems = v
+	case string:
+		if !fromCSV {
+			return nil, fmt.Errorf("expected a list, found %s", describeValue(value))
+		}
+		elems = make([]interface{}, 0)
+		for _, e := range strings.Split(v, CSV_LIST_SEPARATOR) {
+			if e = strings.TrimSpace(e); e != "" {
+				elems = append(elems, e)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("expected a list, found %s", describeValue(value))
+	}
+
+	values := make([]interface{}, 0, len(elems))
+	for _, e := range elems {
+		v, err := convertScalar(field, e, fromCSV)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+func convertScalar(field Field, value interface{}, fromCSV bool) (interface{}, error) {
+	switch field.Type {
+	case NUMERIC_FIELD:
+		switch v := value.(type) {
+		case json.Number:
+			return v, nil
+		case string:
+			s := strings.TrimSpace(v)
+			if s == "" {
+				return nil, nil
+			}
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return json.Number(s), nil
+			}
+		case nil:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("expected a number, found %s", describeValue(value))
+	case BOOLEAN_FIELD:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if !fromCSV {
+				break
+			}
+			if strings.TrimSpace(v) == "" {
+				return nil, nil
+			}
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		case nil:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("expected true or false, found %s", describeValue(value))
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number, bool:
+		if field.Inferred {
+			return fmt.Sprint(v), nil
+		}
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("expected text, found %s", describeValue(value))
+}
+
+// idString returns the _id held by a value read from a data file, or "" when
+// there is none.
+func idString(value interface{}) string {
+	switch v := value.(type) {
+	case json.Number:
+		return string(v)
+	case string:
+		return strings.TrimSpace(v)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case json.Number:
+		return "the number " + string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(value)
+}
+
+// buildDocument flattens a record into the typed values that are indexed.
+// Numbers that do not parse are left out rather than indexed as text. Empty
+// fields are left out too, their names are indexed under EMPTY_FIELD_NAME
+// instead so that they can be searched for. The fields of the records of
+// relations to one record are added as relation.field, and are all empty when
+// there is no related record.
+func buildDocument(schema *Schema, r *record) map[string]interface{} {
+	doc := map[string]interface{}{
+		DOC_TYPE_FIELD_NAME: string(r.entity.DocType),
+	}
+	empty := addDocumentFields(doc, r.entity.Fields, r, "", make([]string, 0))
+	for _, rel := range r.entity.Relations {
+		if rel.toOne() {
+			fields := schema.Type(rel.Type).Fields
+			empty = addDocumentFields(doc, fields, r.related[rel.Name], rel.Name+RELATION_SEPARATOR, empty)
+		}
+	}
+	doc[EMPTY_FIELD_NAME] = empty
+	return doc
+}
+
+// addDocumentFields adds fields of r to doc, their names prefixed with prefix,
+// and returns empty with the names of the empty fields appended. All the
+// fields are empty when r is nil.
+func addDocumentFields(doc map[string]interface{}, fields []Field, r *record, prefix string, empty []string) []string {
+	for _, f := range fields {
+		name := prefix + f.Name
+		var v interface{}
+		if r != nil {
+			v = r.values[f.Name]
+		}
+		if isEmpty(v) {
+			// Booleans are never empty as false cannot be told apart from
+			// missing.
+			if r != nil && f.Type == BOOLEAN_FIELD {
+				doc[name] = false
+				continue
+			}
+			empty = append(empty, name)
+			continue
+		}
+
+		switch v := v.(type) {
+		case json.Number:
+			n, err := v.Float64()
+			if err != nil {
+				continue
+			}
+			doc[name] = n
+		case []interface{}:
+			doc[name] = indexedList(v)
+		default:
+			doc[name] = v
+		}
+	}
+	return empty
+}
+
+// indexedList converts the numbers of a list to the values that are indexed.
+func indexedList(values []interface{}) []interface{} {
+	indexed := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if n, ok := v.(json.Number); ok {
+			f, err := n.Float64()
+			if err != nil {
+				continue
+			}
+			indexed = append(indexed, f)
+			continue
+		}
+		indexed = append(indexed, v)
+	}
+	return indexed
+}
+
+// isEmpty reports whether a value was missing, null or blank in the data
+// files.
+func isEmpty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// buildResult converts the fields of a record into the values displayed for a
+// search result. Missing values are shown as the zero value of their type,
+// numbers as strings.
+func buildResult(r *record) map[string]interface{} {
+	result := make(map[string]interface{}, len(r.entity.Fields))
+	for _, f := range r.entity.Fields {
+		v, ok := r.values[f.Name]
+		switch {
+		case f.Array && !ok:
+			result[f.Name] = make([]interface{}, 0)
+		case f.Array:
+			values := make([]interface{}, len(v.([]interface{})))
+			copy(values, v.([]interface{}))
+			result[f.Name] = values
+		case f.Type == BOOLEAN_FIELD && !ok:
+			result[f.Name] = false
+		case !ok:
+			result[f.Name] = ""
+		case f.Type == NUMERIC_FIELD:
+			result[f.Name] = string(v.(json.Number))
+		default:
+			result[f.Name] = v
+		}
+	}
+	return result
+}
+
+// buildTypedResult converts the fields of a record as buildResult does, but
+// keeps numbers as json.Number values, nil when missing.
+func buildTypedResult(r *record) map[string]interface{} {
+	result := buildResult(r)
+	for _, f := range r.entity.Fields {
+		if f.Type != NUMERIC_FIELD || f.Array {
+			continue
+		}
+		if v, ok := r.values[f.Name]; ok {
+			result[f.Name] = v
+		} else {
+			result[f.Name] = nil
+		}
+	}
+	return result
+}
+
+// buildRecordResult converts a record into a search result, showing its
+// related records by the field named by the Display of each relation.
+func buildRecordResult(r *record) map[string]interface{} {
+	m := buildResult(r)
+	for _, rel := range r.entity.Relations {
+		if rel.Display == "" {
+			continue
+		}
+		if rel.toOne() {
+			m[rel.Name] = ""
+			if related := r.related[rel.Name]; related != nil {
+				m[rel.Name] = buildResult(related)[rel.Display]
+			}
+			continue
+		}
+		for i, related := range r.lists[rel.Name] {
+			m[fmt.Sprintf("%s_%d", rel.DisplayAs, i)] = buildResult(related)[rel.Display]
+		}
+	}
+	return m
+}
+
+// buildRecordDetail converts a record and its related records into nested
+// results.
+func buildRecordDetail(r *record) map[string]interface{} {
+	return buildNestedResult(r, buildResult)
+}
+
+// buildNestedResult converts a record with build, nesting the results of its
+// related records converted the same way. A missing related record is nil.
+func buildNestedResult(r *record, build func(*record) map[string]interface{}) map[string]interface{} {
+	m := build(r)
+	for _, rel := range r.entity.Relations {
+		if rel.toOne() {
+			var related map[string]interface{}
+			if r.related[rel.Name] != nil {
+				related = build(r.related[rel.Name])
+			}
+			m[rel.Name] = related
+			continue
+		}
+		results := make([]map[string]interface{}, len(r.lists[rel.Name]))
+		for i, related := range r.lists[rel.Name] {
+			results[i] = build(related)
+		}
+		m[rel.Name] = results
+	}
+	return m
+}
+
+// buildFieldValues converts the fields of a record, and those of the records
+// of its relations to one record, into the values displayed for each
+// searchable field.
+func buildFieldValues(r *record) map[string]interface{} {
+	values := buildResult(r)
+	for _, rel := range r.entity.Relations {
+		related := r.related[rel.Name]
+		if !rel.toOne() || related == nil {
+			continue
+		}
+		for k, v := range buildResult(related) {
+			values[rel.Name+RELATION_SEPARATOR+k] = v
+		}
+	}
+	return values
+}
@@ -0,0 +1,131 @@
+package search
+
+import (
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// DEFAULT_LIMIT is the page size used by the command line when none is given.
+const DEFAULT_LIMIT = 10
+
+// Request is a search for the records matching Query, returning the page
+// of at most Limit hits that starts at Offset. A Limit of zero or less returns
+// every hit from Offset onwards. Sort lists the fields to order the hits by,
+// each prefixed with "-" for descending order, e.g. "-due_at". When Highlight
+// is set, the matched terms of text and keyword fields are returned as
+// fragments emphasised in that style. Explain returns an explanation of each
+// hit. Nested returns each hit with its related entities nested as records of
+// their own, as Get does, rather than their names, and with numbers as
+// json.Number values, nil when missing, so that hits can be written as data.
+type Request struct {
+	Query     Query
+	Offset    int
+	Limit     int
+	Sort      []string
+	Highlight HighlightStyle
+	Explain   bool
+	Nested    bool
+}
+
+// Results is a page of search hits along with the total number of records
+// that matched the search. Scores holds the relevance score of each hit, in
+// the same order as Hits. When highlighting was requested, Fragments holds the
+// highlighted fragments of each hit keyed by field name, and when explanations
+// were requested Explanations holds the explanation of each hit.
+type Results struct {
+	Total        uint64
+	Offset       int
+	Hits         []map[string]interface{}
+	Scores       []float64
+	Fragments    []map[string][]string
+	Explanations []*Explanation
+}
+
+// buildSearchQuery restricts the search to the current search type and, when
+// q is not nil, to the records matching q.
+func (svc *Service) buildSearchQuery(q Query) (query.Query, error) {
+	docTypeQuery := bleve.NewTermQuery(string(svc.docType()))
+	docTypeQuery.SetField(DOC_TYPE_FIELD_NAME)
+	if q == nil {
+		return docTypeQuery, nil
+	}
+
+	recordQuery, err := q.build(svc)
+	if err != nil {
+		return nil, err
+	}
+	return bleve.NewConjunctionQuery(docTypeQuery, recordQuery), nil
+}
+
+// Find runs a paged search against the records of the current search type.
+func (svc *Service) Find(req Request) (*Results, error) {
+	searchQuery, err := svc.buildSearchQuery(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	size := req.Limit
+	if size <= 0 {
+		count, err := svc.index.DocCount()
+		if err != nil {
+			return nil, err
+		}
+		size = int(count)
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	sortOrder, err := svc.buildSortOrder(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	highlight, err := buildHighlight(req.Highlight, svc.fieldDefinitions())
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(searchQuery, size, offset, false)
+	searchRequest.SortByCustom(sortOrder)
+	searchRequest.Highlight = highlight
+	searchRequest.Explain = req.Explain
+	searchRequest.IncludeLocations = req.Explain
+	searchRequest.Fields = []string{SOURCE_FIELD_NAME}
+	searchResult, err := svc.index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{
+		Total:  searchResult.Total,
+		Offset: offset,
+		Hits:   make([]map[string]interface{}, 0, len(searchResult.Hits)),
+		Scores: make([]float64, 0, len(searchResult.Hits)),
+	}
+	links := svc.newLinker()
+	for _, hit := range searchResult.Hits {
+		record, err := links.hit(hit, true)
+		if err != nil {
+			return nil, err
+		}
+		if req.Nested {
+			results.Hits = append(results.Hits, buildNestedResult(record, buildTypedResult))
+		} else {
+			results.Hits = append(results.Hits, buildRecordResult(record))
+		}
+		results.Scores = append(results.Scores, hit.Score)
+		if highlight != nil {
+			results.Fragments = append(results.Fragments, buildFragments(hit))
+		}
+		if req.Explain {
+			expl, err := svc.buildExplanation(hit, record, req.Query)
+			if err != nil {
+				return nil, err
+			}
+			results.Explanations = append(results.Explanations, expl)
+		}
+	}
+	return results, nil
+}
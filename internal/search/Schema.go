@@ -0,0 +1,403 @@
+package search
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/analyzer/simple"
+	"github.com/blevesearch/bleve/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"gopkg.in/yaml.v2"
+)
+
+// Schema declares the entity types that are loaded and searched: the data
+// file of each type, its fields and how they are indexed, and how its records
+// relate to those of other types.
+type Schema struct {
+	Types []*EntityType `yaml:"types"`
+
+	byName map[Type]*EntityType
+}
+
+// EntityType declares a type of record, e.g. tickets. Name is the name the
+// type is searched by, and DocType the name of a single record, e.g. ticket.
+// File is the name of its data file in the data directory. Every record has a
+// primary key named ID_FIELD_NAME, which must be one of Fields.
+type EntityType struct {
+	Name      Type       `yaml:"name"`
+	DocType   DocType    `yaml:"doc_type"`
+	File      string     `yaml:"file"`
+	Fields    []Field    `yaml:"fields"`
+	Relations []Relation `yaml:"relations,omitempty"`
+
+	// fields lists Fields followed by the fields of the records of the
+	// relations to one record, which are searchable as relation.field.
+	fields []Field
+}
+
+// Relation declares the records of another type that a record relates to.
+// A relation to one record, e.g. the organization of a ticket, names the Field
+// holding the _id of that record. A relation to many records, e.g. the
+// tickets assigned to a user, names the Inverse field of the records of Type
+// holding the _id of this one instead.
+//
+// Display names a field of the related records shown in search results in
+// place of the records themselves: under the name of the relation for a
+// relation to one record, and numbered from 0 after DisplayAs for a relation
+// to many, e.g. assigned_ticket_0.
+type Relation struct {
+	Name      string `yaml:"name"`
+	Type      Type   `yaml:"type"`
+	Field     string `yaml:"field,omitempty"`
+	Inverse   string `yaml:"inverse,omitempty"`
+	Display   string `yaml:"display,omitempty"`
+	DisplayAs string `yaml:"display_as,omitempty"`
+}
+
+// toOne reports whether the relation is to a single record.
+func (r Relation) toOne() bool {
+	return r.Field != ""
+}
+
+// DEFAULT_DATA_DIR is the data directory used when none is given, relative to
+// the working directory.
+const DEFAULT_DATA_DIR = "data"
+
+// DEFAULT_SCHEMA declares the users, tickets and organizations of a Zendesk
+// export.
+const DEFAULT_SCHEMA = `types:
+  - name: Users
+    doc_type: user
+    file: users.json
+    fields:
+      - {name: _id, type: numeric}
+      - {name: url, type: text}
+      - {name: external_id, type: text}
+      - {name: name, type: text}
+      - {name: alias, type: text}
+      - {name: created_at, type: datetime}
+      - {name: active, type: boolean}
+      - {name: shared, type: boolean}
+      - {name: verified, type: boolean}
+      - {name: locale, type: text}
+      - {name: timezone, type: text}
+      - {name: last_login_at, type: datetime}
+      - {name: email, type: text}
+      - {name: phone, type: text}
+      - {name: signature, type: text}
+      - {name: organization_id, type: numeric}
+      - {name: tags, type: text, list: true}
+      - {name: suspended, type: boolean}
+      - {name: role, type: text, values: [end-user, agent, admin]}
+    relations:
+      - {name: organization, type: Organizations, field: organization_id, display: name}
+      - {name: submitted_tickets, type: Tickets, inverse: submitter_id, display: subject, display_as: submitted_ticket}
+      - {name: assigned_tickets, type: Tickets, inverse: assignee_id, display: subject, display_as: assigned_ticket}
+
+  - name: Tickets
+    doc_type: ticket
+    file: tickets.json
+    fields:
+      - {name: _id, type: keyword}
+      - {name: url, type: text}
+      - {name: external_id, type: text}
+      - {name: created_at, type: datetime}
+      - {name: type, type: text, values: [problem, incident, question, task]}
+      - {name: subject, type: text}
+      - {name: description, type: text}
+      - {name: priority, type: text, values: [low, normal, high, urgent]}
+      - {name: status, type: text, values: [new, open, pending, hold, solved, closed]}
+      - {name: tags, type: text, list: true}
+      - {name: has_incidents, type: boolean}
+      - {name: due_at, type: datetime}
+      - {name: via, type: text, values: [web, email, chat, voice, api]}
+      - {name: submitter_id, type: numeric}
+      - {name: assignee_id, type: numeric}
+      - {name: organization_id, type: numeric}
+    relations:
+      - {name: submitter, type: Users, field: submitter_id, display: name}
+      - {name: assignee, type: Users, field: assignee_id, display: name}
+      - {name: organization, type: Organizations, field: organization_id, display: name}
+
+  - name: Organizations
+    doc_type: organization
+    file: organizations.json
+    fields:
+      - {name: _id, type: numeric}
+      - {name: url, type: text}
+      - {name: external_id, type: keyword}
+      - {name: name, type: text}
+      - {name: domain_names, type: text, list: true}
+      - {name: created_at, type: datetime}
+      - {name: details, type: text}
+      - {name: shared_tickets, type: boolean}
+      - {name: tags, type: text, list: true}
+    relations:
+      - {name: users, type: Users, inverse: organization_id}
+      - {name: tickets, type: Tickets, inverse: organization_id}
+`
+
+// The types of DefaultSchema.
+const (
+	USER_SEARCH         Type = "Users"
+	ORGANIZATION_SEARCH Type = "Organizations"
+	TICKET_SEARCH       Type = "Tickets"
+)
+
+const (
+	USER_DOC_TYPE         DocType = "user"
+	ORGANIZATION_DOC_TYPE DocType = "organization"
+	TICKET_DOC_TYPE       DocType = "ticket"
+)
+
+// DefaultSchema is the schema used when none is given.
+var DefaultSchema = mustParseSchema(DEFAULT_SCHEMA)
+
+func mustParseSchema(s string) *Schema {
+	schema, err := LoadSchema(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// analyzers lists the analyzers that text fields can be declared with.
+var analyzers = []string{en.AnalyzerName, standard.Name, simple.Name, keyword.Name}
+
+// LoadSchema reads a schema declared in YAML, or in JSON, from r and checks
+// that it is complete and consistent.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var schema Schema
+	if err := yaml.UnmarshalStrict(b, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if err := schema.compile(); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	return &schema, nil
+}
+
+// compile checks the schema and works out the searchable fields of each type.
+func (s *Schema) compile() error {
+	if len(s.Types) == 0 {
+		return fmt.Errorf("no types declared")
+	}
+	s.byName = make(map[Type]*EntityType, len(s.Types))
+	docTypes := make(map[DocType]bool, len(s.Types))
+	for _, t := range s.Types {
+		if t.Name == "" || t.DocType == "" || t.File == "" {
+			return fmt.Errorf("every type needs a name, doc_type and file")
+		}
+		if _, ok := s.byName[t.Name]; ok {
+			return fmt.Errorf("type %s is declared twice", t.Name)
+		}
+		if docTypes[t.DocType] {
+			return fmt.Errorf("doc_type %s is declared twice", t.DocType)
+		}
+		s.byName[t.Name] = t
+		docTypes[t.DocType] = true
+		if err := t.checkFields(); err != nil {
+			return fmt.Errorf("type %s: %v", t.Name, err)
+		}
+	}
+
+	for _, t := range s.Types {
+		t.fields = append([]Field(nil), t.Fields...)
+		relations := make(map[string]bool, len(t.Relations))
+		for _, r := range t.Relations {
+			if err := s.checkRelation(t, r); err != nil {
+				return fmt.Errorf("type %s, relation %s: %v", t.Name, r.Name, err)
+			}
+			if relations[r.Name] || t.field(r.Name) != nil {
+				return fmt.Errorf("type %s: relation %s has the name of another field or relation", t.Name, r.Name)
+			}
+			relations[r.Name] = true
+			if !r.toOne() {
+				continue
+			}
+			for _, f := range s.byName[r.Type].Fields {
+				f.Relation = r.Name
+				f.Name = r.Name + RELATION_SEPARATOR + f.Name
+				t.fields = append(t.fields, f)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *EntityType) checkFields() error {
+	names := make(map[string]bool, len(t.Fields))
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		if f.Name == "" || strings.Contains(f.Name, RELATION_SEPARATOR) || strings.HasPrefix(f.Name, "_") && f.Name != ID_FIELD_NAME {
+			return fmt.Errorf("invalid field name %q", f.Name)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("field %s is declared twice", f.Name)
+		}
+		names[f.Name] = true
+		if f.Type == "" {
+			f.Type = TEXT_FIELD
+		}
+		if !contains(fieldTypeNames(), string(f.Type)) {
+			return fmt.Errorf("field %s: unknown type %q, expected one of %s", f.Name, f.Type, strings.Join(fieldTypeNames(), ", "))
+		}
+		if f.Analyzer != "" && f.Type != TEXT_FIELD {
+			return fmt.Errorf("field %s: only text fields have an analyzer", f.Name)
+		}
+		if f.Analyzer != "" && !contains(analyzers, f.Analyzer) {
+			return fmt.Errorf("field %s: unknown analyzer %q, expected one of %s", f.Name, f.Analyzer, strings.Join(analyzers, ", "))
+		}
+		if f.Array && f.Name == ID_FIELD_NAME {
+			return fmt.Errorf("field %s cannot be a list", f.Name)
+		}
+	}
+	if !names[ID_FIELD_NAME] {
+		return fmt.Errorf("no %s field declared", ID_FIELD_NAME)
+	}
+	return nil
+}
+
+func (s *Schema) checkRelation(t *EntityType, r Relation) error {
+	target, ok := s.byName[r.Type]
+	if !ok {
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+	if r.Name == "" {
+		return fmt.Errorf("no name given")
+	}
+	if (r.Field == "") == (r.Inverse == "") {
+		return fmt.Errorf("exactly one of field and inverse must be given")
+	}
+	if r.toOne() && t.field(r.Field) == nil {
+		return fmt.Errorf("unknown field %s", r.Field)
+	}
+	if !r.toOne() && target.field(r.Inverse) == nil {
+		return fmt.Errorf("unknown field %s of %s", r.Inverse, target.Name)
+	}
+	if r.Display != "" && target.field(r.Display) == nil {
+		return fmt.Errorf("unknown field %s of %s", r.Display, target.Name)
+	}
+	if r.DisplayAs != "" && (r.toOne() || r.Display == "") {
+		return fmt.Errorf("display_as is only given with display for a relation to many records")
+	}
+	if !r.toOne() && r.Display != "" && r.DisplayAs == "" {
+		return fmt.Errorf("display_as must be given with display for a relation to many records")
+	}
+	return nil
+}
+
+func fieldTypeNames() []string {
+	return []string{string(TEXT_FIELD), string(KEYWORD_FIELD), string(NUMERIC_FIELD), string(BOOLEAN_FIELD), string(DATETIME_FIELD)}
+}
+
+// field returns the declared field named name, or nil when there is none.
+func (t *EntityType) field(name string) *Field {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Type returns the entity type named name, or nil when there is none.
+func (s *Schema) Type(name Type) *EntityType {
+	return s.byName[name]
+}
+
+// TypeNames returns the names of the types in the order they are declared,
+// which is the order they are offered to the user.
+func (s *Schema) TypeNames() []string {
+	names := make([]string, len(s.Types))
+	for i, t := range s.Types {
+		names[i] = string(t.Name)
+	}
+	return names
+}
+
+// ParseType resolves a type from user input, ignoring case and accepting the
+// doc type name, e.g. "tickets", "Tickets" or "ticket".
+func (s *Schema) ParseType(name string) (Type, error) {
+	for _, t := range s.Types {
+		if strings.EqualFold(name, string(t.Name)) || strings.EqualFold(name, string(t.DocType)) {
+			return t.Name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown search type %q, expected one of %s", name, strings.Join(s.TypeNames(), ", "))
+}
+
+// DefaultFiles locates the data files in DEFAULT_DATA_DIR.
+func (s *Schema) DefaultFiles() Files {
+	files := make(Files, len(s.Types))
+	for _, t := range s.Types {
+		files[t.Name] = "." + string(filepath.Separator) + filepath.Join(DEFAULT_DATA_DIR, t.File)
+	}
+	return files
+}
+
+// DataFiles locates the data files in dir under the file name of each type.
+func (s *Schema) DataFiles(dir string) Files {
+	files := make(Files, len(s.Types))
+	for _, t := range s.Types {
+		files[t.Name] = filepath.Join(dir, t.File)
+	}
+	return files
+}
+
+// loadOrder lists the types so that the types their relations to one record
+// refer to come first where possible.
+func (s *Schema) loadOrder() []*EntityType {
+	order := make([]*EntityType, 0, len(s.Types))
+	visited := make(map[Type]bool, len(s.Types))
+	var visit func(t *EntityType)
+	visit = func(t *EntityType) {
+		if visited[t.Name] {
+			return
+		}
+		visited[t.Name] = true
+		for _, r := range t.Relations {
+			if r.toOne() {
+				visit(s.byName[r.Type])
+			}
+		}
+		order = append(order, t)
+	}
+	for _, t := range s.Types {
+		visit(t)
+	}
+	return order
+}
+
+// allTypes returns the names of all the types of the schema.
+func (s *Schema) allTypes() map[Type]bool {
+	types := make(map[Type]bool, len(s.Types))
+	for _, t := range s.Types {
+		types[t.Name] = true
+	}
+	return types
+}
+
+// withRelated returns types along with the types their relations to one
+// record refer to, and those of their relations to many records too when
+// toMany is set.
+func (s *Schema) withRelated(types map[Type]bool, toMany bool) map[Type]bool {
+	related := make(map[Type]bool, len(types))
+	for name := range types {
+		related[name] = true
+		for _, r := range s.byName[name].Relations {
+			if r.toOne() || toMany {
+				related[r.Type] = true
+			}
+		}
+	}
+	return related
+}
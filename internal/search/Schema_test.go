@@ -0,0 +1,129 @@
+package search_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+var agentsSchema = `types:
+  - name: Agents
+    doc_type: agent
+    file: agents.json
+    fields:
+      - {name: _id, type: numeric}
+      - {name: name, type: text, analyzer: standard}
+      - {name: group_id, type: numeric}
+      - {name: skills, type: keyword, list: true}
+    relations:
+      - {name: group, type: Groups, field: group_id, display: name}
+  - name: Groups
+    doc_type: group
+    file: groups.json
+    fields:
+      - {name: _id, type: numeric}
+      - {name: name}
+    relations:
+      - {name: agents, type: Agents, inverse: group_id, display: name, display_as: agent}
+`
+
+var agentsJson = `[
+	{"_id": 10, "name": "Ada Lovelace", "group_id": 1, "skills": ["billing", "refunds"]},
+	{"_id": 11, "name": "Alan Turing", "group_id": 2}
+]`
+
+var groupsJson = `[{"_id": 1, "name": "Support Team"}, {"_id": 2, "name": "Billing"}]`
+
+func newAgentsService(t *testing.T) *search.Service {
+	schema, err := search.LoadSchema(strings.NewReader(agentsSchema))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mfs := &mockFileService{}
+	mfs.On("Open", "data/agents.json").Return([]byte(agentsJson), nil)
+	mfs.On("Open", "data/groups.json").Return([]byte(groupsJson), nil)
+	return search.NewWithSchema(mfs, schema, schema.DataFiles("data"))
+}
+
+func TestSchemaDrivesFieldsAndJoins(t *testing.T) {
+	svc := newAgentsService(t)
+	assert.Equal(t, []string{"Agents", "Groups"}, svc.Schema().TypeNames())
+
+	agents, err := svc.Schema().ParseType("agent")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err = svc.Init(agents); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, []string{"_id", "name", "group_id", "skills", "group._id", "group.name"}, svc.ListFields())
+
+	result, err := svc.Search("group.name", "billing")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "Alan Turing", result[0]["name"])
+	assert.Equal(t, "Billing", result[0]["group"])
+	assert.Equal(t, []interface{}{}, result[0]["skills"])
+
+	result, err = svc.SearchEmpty("skills")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+
+	// The standard analyzer does not stem, unlike the default.
+	terms, err := svc.Analyze("name", "Running Lovelace")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, []string{"running", "lovelace"}, terms)
+
+	groups, _ := svc.Schema().ParseType("groups")
+	svc.Init(groups)
+	group, err := svc.Get("1")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	members := group["agents"].([]map[string]interface{})
+	assert.Equal(t, 1, len(members))
+	assert.Equal(t, "Ada Lovelace", members[0]["name"])
+
+	result, err = svc.Search("name", "support")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, "Ada Lovelace", result[0]["agent_0"])
+}
+
+func TestLoadSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{"no types", `types: []`, "no types declared"},
+		{"unknown key", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}], index: true}]`, "field index not found"},
+		{"no id", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: name}]}]`, "type A: no _id field declared"},
+		{"duplicate type", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}]}, {name: A, doc_type: b, file: b.json, fields: [{name: _id}]}]`, "type A is declared twice"},
+		{"duplicate field", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}, {name: _id}]}]`, "field _id is declared twice"},
+		{"field type", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id, type: date}]}]`, `unknown type "date"`},
+		{"analyzer", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}, {name: b, analyzer: fr}]}]`, `unknown analyzer "fr"`},
+		{"relation type", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}, {name: b_id}], relations: [{name: b, type: B, field: b_id}]}]`, `relation b: unknown type "B"`},
+		{"relation field", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}], relations: [{name: a, type: A, field: a_id}]}]`, "relation a: unknown field a_id"},
+		{"relation kind", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}], relations: [{name: a, type: A}]}]`, "exactly one of field and inverse"},
+		{"display as", `types: [{name: A, doc_type: a, file: a.json, fields: [{name: _id}, {name: a_id}], relations: [{name: as, type: A, inverse: a_id, display: _id}]}]`, "display_as must be given"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := search.LoadSchema(strings.NewReader(tt.schema))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "invalid schema")
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
@@ -1,444 +1,501 @@
 package search
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/blevesearch/bleve"
-	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/index/store/goleveldb"
+	"github.com/blevesearch/bleve/index/upsidedown"
 	"github.com/blevesearch/bleve/mapping"
-	"github.com/google/uuid"
 )
 
+// Type is the name of an entity type of the schema, e.g. Tickets.
 type Type string
 
-const (
-	USER_SEARCH         Type = "Users"
-	ORGANIZATION_SEARCH Type = "Organizations"
-	TICKET_SEARCH       Type = "Tickets"
-)
-
 const DOC_TYPE_FIELD_NAME = "DocType"
 
+// DocType is the name of a single record of an entity type, e.g. ticket.
 type DocType string
 
-const (
-	USER_DOC_TYPE         DocType = "user"
-	ORGANIZATION_DOC_TYPE DocType = "organization"
-	TICKET_DOC_TYPE       DocType = "ticket"
-)
+// ErrNotFound is returned when a record looked up by its _id does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrUnknownField is returned when a field is not searchable for the search
+// type.
+var ErrUnknownField = errors.New("unknown field")
 
+// FileService opens the data files for reading.
 type FileService interface {
-	ReadFile(fileName string) ([]byte, error)
+	Open(fileName string) (io.ReadCloser, error)
 }
 
+// Files locates the data file of each type.
+type Files map[Type]string
+
 type Service struct {
 	index      bleve.Index
 	searchType Type
-	fs         FileService
+	// searched holds the types that Init indexed to be searched, as opposed
+	// to those indexed to be shown with them.
+	searched map[Type]bool
+	fs       FileService
+	// declared is the schema the service was created with, and schema the
+	// schema of the records loaded, which adds the fields inferred from the
+	// data files.
+	declared *Schema
+	schema   *Schema
+	files    Files
+	// indexDir is the directory of the persistent index, when there is one,
+	// and tempDir that of the index built for the service alone otherwise.
+	indexDir string
+	tempDir  string
 }
 
+// New returns a Service reading the data of DefaultSchema from
+// DEFAULT_DATA_DIR.
 func New(fs FileService) *Service {
+	return NewWithFiles(fs, DefaultSchema.DefaultFiles())
+}
+
+// NewWithFiles returns a Service reading the data of DefaultSchema from files.
+func NewWithFiles(fs FileService, files Files) *Service {
+	return NewWithSchema(fs, DefaultSchema, files)
+}
+
+// NewWithSchema returns a Service reading the data of the types of schema from
+// files.
+func NewWithSchema(fs FileService, schema *Schema, files Files) *Service {
 	svc := &Service{
-		fs: fs,
+		fs:       fs,
+		declared: schema,
+		schema:   schema,
+		files:    files,
 	}
 	return svc
 }
 
-func (svc *Service) Init(searchType Type) error {
-	svc.searchType = searchType
+// Schema returns the schema of the records searched.
+func (svc *Service) Schema() *Schema {
+	return svc.schema
+}
 
-	users, err := svc.unmarshalUsers()
+// INDEX_BATCH_SIZE is the number of records indexed at a time.
+const INDEX_BATCH_SIZE = 1000
+
+// Init loads the data files and indexes the records of searchType, or those
+// of every type when searchType is empty. Besides its own, only the records of
+// the types that searchType relates to are loaded, to be shown with its
+// records. The records are kept in an index on disk rather than in memory:
+// each record is indexed as it is decoded, and read back from the index for
+// search results, along with the records it relates to. The records that
+// other records are linked to are loaded first where possible.
+//
+// Fields found in the data files that the schema does not declare are added
+// to the schema of the service with the types inferred from their values, so
+// that they are indexed and shown in results too.
+//
+// When a persistent index is used, it is opened rather than built unless any
+// of the data files has changed since it was built. A persistent index holds
+// the records of every type. Otherwise the index is built in a temporary
+// directory, which is removed by Close.
+func (svc *Service) Init(searchType Type) error {
+	indexed, err := svc.searchedTypes(searchType)
 	if err != nil {
 		return err
 	}
-
-	orgs, err := svc.unmarshalOrganizations()
-	if err != nil {
+	svc.searchType = searchType
+	svc.searched = indexed
+	if err := svc.Close(); err != nil {
 		return err
 	}
-
-	tickets, err := svc.unmarshalTickets()
-	if err != nil {
-		return err
+	if svc.indexDir != "" {
+		return svc.openPersistentIndex(false)
 	}
-
-	if err = svc.buildIndex(users, orgs, tickets); err != nil {
+	if svc.tempDir, err = ioutil.TempDir("", TEMP_INDEX_PREFIX); err != nil {
 		return err
 	}
-	return nil
+	if err = svc.buildIndex(newDiskIndex(filepath.Join(svc.tempDir, BLEVE_DIR_NAME)), indexed); err != nil {
+		svc.Close()
+	}
+	return err
 }
 
-type User struct {
-	Id               json.Number `json:"_id"`
-	Url              string      `json:"url"`
-	ExternalId       string      `json:"external_id"`
-	Name             string      `json:"name"`
-	Alias            string      `json:"alias"`
-	CreatedAt        string      `json:"created_at"`
-	Active           bool        `json:"active"`
-	Shared           bool        `json:"shared"`
-	Verified         bool        `json:"verified"`
-	Locale           string      `json:"locale"`
-	TimeZone         string      `json:"timezone"`
-	LastLoginAt      string      `json:"last_login_at"`
-	Email            string      `json:"email"`
-	Phone            string      `json:"phone"`
-	Signature        string      `json:"signature"`
-	OrganizationId   json.Number `json:"organization_id"`
-	Tags             []string    `json:"tags"`
-	Suspended        bool        `json:"suspended"`
-	Role             string      `json:"role"`
-	DocType          DocType
-	Organization     Organization
-	SubmittedTickets []Ticket
-	AssignedTickets  []Ticket
-}
-
-func getFields(target interface{}) []string {
-	val := reflect.ValueOf(target).Elem()
-
-	fields := make([]string, 0)
-	for i := 0; i < val.NumField(); i++ {
-		typeField := val.Type().Field(i)
-		tag := typeField.Tag
-
-		field := tag.Get("json")
-		if field != "" && field != DOC_TYPE_FIELD_NAME {
-			fields = append(fields, field)
+// TEMP_INDEX_PREFIX prefixes the name of the temporary directory of an index
+// that is not persisted.
+const TEMP_INDEX_PREFIX = "zen-index-"
+
+// Close closes the index, removing it when it is not persisted. The service
+// can be initialised again after it is closed.
+func (svc *Service) Close() error {
+	var err error
+	if svc.index != nil {
+		err = svc.index.Close()
+		svc.index = nil
+	}
+	if svc.tempDir != "" {
+		if removeErr := os.RemoveAll(svc.tempDir); err == nil {
+			err = removeErr
 		}
+		svc.tempDir = ""
 	}
-	return fields
-}
-
-func buildUserMapping(keywordMapping *mapping.FieldMapping, textFieldMapping *mapping.FieldMapping) *mapping.DocumentMapping {
-	userMapping := bleve.NewDocumentMapping()
-	userMapping.AddFieldMappingsAt("_id", keywordMapping)
-	userMapping.AddFieldMappingsAt("url", textFieldMapping)
-	userMapping.AddFieldMappingsAt("external_id", textFieldMapping)
-	userMapping.AddFieldMappingsAt("name", textFieldMapping)
-	userMapping.AddFieldMappingsAt("alias", textFieldMapping)
-	userMapping.AddFieldMappingsAt("created_at", textFieldMapping)
-	userMapping.AddFieldMappingsAt("active", textFieldMapping)
-	userMapping.AddFieldMappingsAt("verified", keywordMapping)
-	userMapping.AddFieldMappingsAt("shared", keywordMapping)
-	userMapping.AddFieldMappingsAt("locale", textFieldMapping)
-	userMapping.AddFieldMappingsAt("timezone", textFieldMapping)
-	userMapping.AddFieldMappingsAt("last_login_at", textFieldMapping)
-	userMapping.AddFieldMappingsAt("email", textFieldMapping)
-	userMapping.AddFieldMappingsAt("phone", textFieldMapping)
-	userMapping.AddFieldMappingsAt("signature", textFieldMapping)
-	userMapping.AddFieldMappingsAt("organization_id", keywordMapping)
-	userMapping.AddFieldMappingsAt("tags", textFieldMapping)
-	userMapping.AddFieldMappingsAt("suspended", keywordMapping)
-	userMapping.AddFieldMappingsAt("role", textFieldMapping)
-
-	return userMapping
-}
-
-type Organization struct {
-	Id            json.Number `json:"_id"`
-	Url           string      `json:"url"`
-	ExternalId    string      `json:"external_id"`
-	Name          string      `json:"name"`
-	DomainNames   []string    `json:"domain_names"`
-	CreatedAt     string      `json:"created_at"`
-	Details       string      `json:"details"`
-	SharedTickets bool        `json:"shared_tickets"`
-	Tags          []string    `json:"tags"`
-	Users         []User
-	DocType       DocType
-	Tickets       []Ticket
-}
-
-func buildOrganizationMapping(keywordMapping *mapping.FieldMapping, textFieldMapping *mapping.FieldMapping) *mapping.DocumentMapping {
-	orgMapping := bleve.NewDocumentMapping()
-	orgMapping.AddFieldMappingsAt("_id", keywordMapping)
-	orgMapping.AddFieldMappingsAt("name", textFieldMapping)
-	orgMapping.AddFieldMappingsAt("external_id", keywordMapping)
-	orgMapping.AddFieldMappingsAt("domain_names", textFieldMapping)
-	orgMapping.AddFieldMappingsAt("created_at", textFieldMapping)
-	orgMapping.AddFieldMappingsAt("details", textFieldMapping)
-	orgMapping.AddFieldMappingsAt("shared_tickets", textFieldMapping)
-	orgMapping.AddFieldMappingsAt("tags", textFieldMapping)
-
-	return orgMapping
-}
-
-type Ticket struct {
-	Id             string   `json:"_id"`
-	Url            string   `json:"url"`
-	ExternalId     string   `json:"external_id"`
-	CreatedAt      string   `json:"created_at"`
-	Type           string   `json:"type"`
-	Subject        string   `json:"subject"`
-	Description    string   `json:"description"`
-	Priority       string   `json:"priority"`
-	Status         string   `json:"status"`
-	Tags           []string `json:"tags"`
-	HasIncidents   bool     `json:"has_incidents"`
-	DueAt          string   `json:"due_at"`
-	Via            string   `json:"via"`
-	DocType        DocType
-	Submitter      User
-	SubmitterId    json.Number `json:"submitter_id"`
-	AssigneeId     json.Number `json:"assignee_id"`
-	Assignee       User
-	OrganizationId json.Number `json:"organization_id"`
-	Organization   Organization
-}
-
-func buildTicketMapping(keywordMapping *mapping.FieldMapping, textFieldMapping *mapping.FieldMapping) *mapping.DocumentMapping {
-	ticketMapping := bleve.NewDocumentMapping()
-	ticketMapping.AddFieldMappingsAt("_id", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("url", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("external_id", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("created_at", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("type", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("subject", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("description", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("priority", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("status", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("tags", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("has_incidents", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("due_at", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("via", textFieldMapping)
-	ticketMapping.AddFieldMappingsAt("submitter_id", keywordMapping)
-	ticketMapping.AddFieldMappingsAt("assignee_id", keywordMapping)
-	ticketMapping.AddFieldMappingsAt("organization_id", keywordMapping)
-
-	return ticketMapping
-}
-
-func (svc *Service) buildIndex(users []User, organizations []Organization, tickets []Ticket) error {
-	indexMapping := bleve.NewIndexMapping()
-	indexMapping.TypeField = "DocType"
-	indexMapping.DefaultAnalyzer = "en"
-
-	keywordMapping := bleve.NewTextFieldMapping()
-	keywordMapping.Analyzer = keyword.Name
-
-	textFieldMapping := bleve.NewTextFieldMapping()
-	textFieldMapping.Analyzer = en.AnalyzerName
-
-	userMapping := buildUserMapping(keywordMapping, textFieldMapping)
-	orgMapping := buildOrganizationMapping(keywordMapping, textFieldMapping)
-	ticketMapping := buildTicketMapping(keywordMapping, textFieldMapping)
+	return err
+}
 
-	indexMapping.AddDocumentMapping(string(USER_DOC_TYPE), userMapping)
-	indexMapping.AddDocumentMapping(string(ORGANIZATION_DOC_TYPE), orgMapping)
-	indexMapping.AddDocumentMapping(string(TICKET_DOC_TYPE), ticketMapping)
+// SetSearchType makes searchType the current search type without reading any
+// data, so that its declared fields can be listed.
+func (svc *Service) SetSearchType(searchType Type) error {
+	if _, err := svc.searchedTypes(searchType); err != nil {
+		return err
+	}
+	svc.searchType = searchType
+	return nil
+}
 
-	index, err := bleve.NewMemOnly(indexMapping)
+// LoadFields makes searchType the current search type and adds the fields
+// found in its data file, and in those of the types it relates to one record
+// of, that the schema does not declare, without indexing any records. The
+// data files are decoded in full to find them, so LoadIndexedFields is
+// cheaper when there is a persistent index. The declared fields are kept when
+// an error is returned.
+func (svc *Service) LoadFields(searchType Type) error {
+	types, err := svc.searchedTypes(searchType)
+	if err != nil {
+		return err
+	}
+	svc.searchType = searchType
+	extra, err := svc.inferFields(svc.declared.withRelated(types, false))
 	if err != nil {
 		return err
 	}
+	svc.schema, err = svc.declared.withFields(extra)
+	return err
+}
 
-	for _, user := range users {
-		user.DocType = USER_DOC_TYPE
-		user = buildUserGraph(user, organizations, tickets)
-		index.Index(uuid.NewString(), user)
+// searchedTypes returns the types searched for searchType: searchType itself,
+// or every type when it is empty.
+func (svc *Service) searchedTypes(searchType Type) (map[Type]bool, error) {
+	if searchType == "" {
+		return svc.declared.allTypes(), nil
 	}
+	if svc.declared.Type(searchType) == nil {
+		return nil, fmt.Errorf("unknown search type %q, expected one of %s", searchType, strings.Join(svc.declared.TypeNames(), ", "))
+	}
+	return map[Type]bool{searchType: true}, nil
+}
 
-	for _, org := range organizations {
-		org.DocType = ORGANIZATION_DOC_TYPE
-		index.Index(uuid.NewString(), org)
+// newDiskIndex returns a function creating an index on disk at path.
+func newDiskIndex(path string) func(mapping.IndexMapping) (bleve.Index, error) {
+	return func(m mapping.IndexMapping) (bleve.Index, error) {
+		return bleve.NewUsing(path, m, upsidedown.Name, goleveldb.Name, nil)
 	}
+}
 
-	for _, ticket := range tickets {
-		ticket.DocType = TICKET_DOC_TYPE
-		ticket = buildTicketGraph(ticket, organizations, users)
-		index.Index(uuid.NewString(), ticket)
+// buildIndexMapping returns the mapping of the documents of every type of the
+// schema of the service.
+func (svc *Service) buildIndexMapping() (*mapping.IndexMappingImpl, error) {
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.TypeField = DOC_TYPE_FIELD_NAME
+	indexMapping.DefaultAnalyzer = en.AnalyzerName
+	if err := addDateTimeParser(indexMapping); err != nil {
+		return nil, err
 	}
-	svc.index = index
-	return nil
+	if err := addSortAnalyzer(indexMapping); err != nil {
+		return nil, err
+	}
+	svc.addDocumentMappings(indexMapping)
+	return indexMapping, nil
 }
 
-func (svc *Service) Search(searchTerm string, searchValue string) ([]map[string]interface{}, error) {
-	query := bleve.NewMatchQuery(searchValue)
-	query.SetField(searchTerm)
-	searchRequest := bleve.NewSearchRequest(query)
-	searchRequest.Fields = []string{"*"}
-	searchResult, err := svc.index.Search(searchRequest)
+func (svc *Service) addDocumentMappings(indexMapping *mapping.IndexMappingImpl) {
+	for _, t := range svc.schema.Types {
+		indexMapping.AddDocumentMapping(string(t.DocType), buildDocumentMapping(t.fields))
+	}
+}
+
+// buildIndex creates an index with newIndex and indexes the records of the
+// indexed types in it, along with those of the types they relate to.
+func (svc *Service) buildIndex(newIndex func(mapping.IndexMapping) (bleve.Index, error), indexed map[Type]bool) error {
+	svc.schema = svc.declared
+	indexMapping, err := svc.buildIndexMapping()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	if svc.index, err = newIndex(indexMapping); err != nil {
+		return err
+	}
+	if err := svc.indexRecords(indexMapping, svc.declared.withRelated(indexed, true)); err != nil {
+		svc.index.Close()
+		svc.index = nil
+		return err
+	}
+	return nil
+}
 
-	results := make([]map[string]interface{}, 0)
-	for _, result := range searchResult.Hits {
-		if searchTypeToDocType(svc.searchType) == DocType(result.Fields[DOC_TYPE_FIELD_NAME].(string)) {
-			displayFields := svc.ListFields()
-			m := make(map[string]interface{})
-			for k, v := range result.Fields {
-				if contains(displayFields, k) {
-					m[k] = v
-				}
+// indexRecords reads the data files of the loaded types once each, indexing
+// their records as they are decoded and inferring the fields that the schema
+// does not declare from them at the same time. The fields found are added to
+// the schema and to indexMapping once a file has been read, so that the
+// records of the types read after it embed them. The records of a type with
+// fields found, or related to records that were indexed after them, are
+// indexed once more at the end, this time read from the index itself.
+func (svc *Service) indexRecords(indexMapping *mapping.IndexMappingImpl, loaded map[Type]bool) error {
+	counts := make(map[Type]int)
+	extra := make(map[Type][]Field)
+	reindexed := make(map[Type]bool)
+	order := make([]*EntityType, 0, len(loaded))
+	for _, t := range svc.declared.loadOrder() {
+		if !loaded[t.Name] {
+			continue
+		}
+		for _, rel := range t.Relations {
+			// Records are only linked to the records indexed before them.
+			if _, done := counts[rel.Type]; rel.toOne() && loaded[rel.Type] && !done {
+				reindexed[t.Name] = true
 			}
+		}
+		order = append(order, t)
+		counts[t.Name] = 0
+
+		inference := newFieldInference(t)
+		w := svc.newIndexWriter()
+		err := svc.readRaw(t, func(n int, raw map[string]interface{}, fromCSV bool) error {
+			inference.add(raw, fromCSV)
+			counts[t.Name] = n
+			return w.add(n, storedRecord{Type: t.Name, Values: raw, CSV: fromCSV})
+		})
+		if err == nil {
+			err = w.flush()
+		}
+		if err != nil {
+			return err
+		}
 
-			switch svc.searchType {
-			case USER_SEARCH:
-				m = mapAdditionalUserFields(result.Fields, m)
-				break
-			case TICKET_SEARCH:
-				m = mapAdditionalTicketFields(result.Fields, m)
-				break
+		if fields := inference.fields(); len(fields) > 0 {
+			extra[t.Name] = fields
+			if svc.schema, err = svc.declared.withFields(extra); err != nil {
+				return err
 			}
-			results = append(results, m)
+			svc.addDocumentMappings(indexMapping)
+			reindexed[t.Name] = true
 		}
 	}
 
-	return results, nil
-}
-
-func mapAdditionalUserFields(searchResults map[string]interface{}, result map[string]interface{}) map[string]interface{} {
-	result["organization"] = searchResults["Organization.name"]
-	assignedTickets := searchResults["AssignedTickets.subject"].([]interface{})
-	for i, t := range assignedTickets {
-		result[fmt.Sprintf("assigned_ticket_%d", i)] = t
-	}
-
-	submittedTickets := searchResults["SubmittedTickets.subject"].([]interface{})
-	for i, t := range submittedTickets {
-		result[fmt.Sprintf("submitted_ticket_%d", i)] = t
+	for _, t := range order {
+		if reindexed[t.Name] {
+			if err := svc.reindex(t, counts[t.Name]); err != nil {
+				return err
+			}
+		}
 	}
-	return result
+	return nil
 }
 
-func mapAdditionalTicketFields(searchResults map[string]interface{}, result map[string]interface{}) map[string]interface{} {
-	result["organization"] = searchResults["Organization.name"]
-	result["assignee"] = searchResults["Assignee.name"]
-	result["submitter"] = searchResults["Submitter.name"]
-	return result
+// reindex indexes the count records of t once more, reading them from the
+// index.
+func (svc *Service) reindex(t *EntityType, count int) error {
+	w := svc.newIndexWriter()
+	for n := 1; n <= count; n++ {
+		sr, err := svc.readSource(documentID(t, n))
+		if err != nil {
+			return err
+		}
+		if err := w.add(n, sr); err != nil {
+			return err
+		}
+	}
+	return w.flush()
 }
 
-func (svc *Service) ListFields() []string {
-	var fields []string
-	switch svc.searchType {
-	case USER_SEARCH:
-		user := &User{}
-		fields = getFields(user)
-		break
-	case ORGANIZATION_SEARCH:
-		org := &Organization{}
-		fields = getFields(org)
-		break
-	case TICKET_SEARCH:
-		ticket := &Ticket{}
-		fields = getFields(ticket)
-		break
-	}
-	return fields
-}
-
-func (svc *Service) readFile(fileName string) ([]byte, error) {
-	jsonBytes, err := svc.fs.ReadFile(fileName)
+// Get looks up a record of the current search type by its _id. The record is
+// returned with its related entities nested as records of their own.
+func (svc *Service) Get(id string) (map[string]interface{}, error) {
+	// The first record wins when a primary key is duplicated.
+	records, err := svc.findByKey(svc.searchType, ID_FIELD_NAME, strings.TrimSpace(id), 1)
 	if err != nil {
 		return nil, err
 	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: no %s with _id %q", ErrNotFound, svc.docType(), id)
+	}
+	if err := svc.newLinker().link(records[0], true); err != nil {
+		return nil, err
+	}
+	return buildRecordDetail(records[0]), nil
+}
+
+// Search returns all the records of the current search type whose searchTerm
+// field matches searchValue. The value is parsed according to the field type,
+// so booleans, numbers and dates must be given in a form that type accepts.
+// Numeric and date fields also accept the range expressions understood by
+// ParseRange.
+func (svc *Service) Search(searchTerm string, searchValue string) ([]map[string]interface{}, error) {
+	return svc.findAll(Condition{Field: searchTerm, Value: searchValue})
+}
 
-	return jsonBytes, nil
+// SearchRange returns all the records of the current search type whose numeric
+// or date field falls within r.
+func (svc *Service) SearchRange(searchTerm string, r Range) ([]map[string]interface{}, error) {
+	return svc.findAll(Condition{Field: searchTerm, Range: &r})
 }
 
-func (svc *Service) unmarshalUsers() ([]User, error) {
-	fileName := "./data/users.json"
+// SearchEmpty returns all the records of the current search type whose
+// searchTerm field is missing, null, blank or an empty list.
+func (svc *Service) SearchEmpty(searchTerm string) ([]map[string]interface{}, error) {
+	return svc.findAll(Condition{Field: searchTerm, Empty: true})
+}
 
-	b, err := svc.readFile(fileName)
+func (svc *Service) findAll(c Condition) ([]map[string]interface{}, error) {
+	results, err := svc.Find(Request{Query: c})
 	if err != nil {
 		return nil, err
 	}
+	return results.Hits, nil
+}
 
-	var users []User
-	if err = json.Unmarshal(b, &users); err != nil {
-		return nil, err
+func (svc *Service) ListFields() []string {
+	fields := svc.fieldDefinitions()
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
 	}
-	return users, nil
+	return names
 }
 
-func (svc *Service) unmarshalOrganizations() ([]Organization, error) {
-	fileName := "./data/organizations.json"
-
-	b, err := svc.readFile(fileName)
-	if err != nil {
-		return nil, err
+// RelationNames returns the names of the relations of the current search
+// type, under which its related records are nested, in the order the schema
+// declares them.
+func (svc *Service) RelationNames() []string {
+	names := make([]string, 0)
+	if t := svc.entity(); t != nil {
+		for _, rel := range t.Relations {
+			names = append(names, rel.Name)
+		}
 	}
+	return names
+}
 
-	var orgs []Organization
-	if err = json.Unmarshal(b, &orgs); err != nil {
-		return nil, err
+// RelatedFields returns the names of the fields of the records of each
+// relation of the current search type, keyed by the name of the relation, in
+// the order the schema declares them.
+func (svc *Service) RelatedFields() map[string][]string {
+	related := make(map[string][]string)
+	t := svc.entity()
+	if t == nil {
+		return related
 	}
-	return orgs, nil
+	for _, rel := range t.Relations {
+		names := make([]string, 0)
+		if rt := svc.schema.Type(rel.Type); rt != nil {
+			for _, f := range rt.Fields {
+				names = append(names, f.Name)
+			}
+		}
+		related[rel.Name] = names
+	}
+	return related
 }
 
-func (svc *Service) unmarshalTickets() ([]Ticket, error) {
-	fileName := "./data/tickets.json"
+// entity returns the entity type of the current search type, or nil when
+// there is none.
+func (svc *Service) entity() *EntityType {
+	return svc.schema.Type(svc.searchType)
+}
 
-	b, err := svc.readFile(fileName)
-	if err != nil {
-		return nil, err
+// docType returns the doc type of the current search type.
+func (svc *Service) docType() DocType {
+	if t := svc.entity(); t != nil {
+		return t.DocType
 	}
+	return ""
+}
 
-	var tickets []Ticket
-	if err = json.Unmarshal(b, &tickets); err != nil {
-		return nil, err
+// fieldDefinitions returns the searchable fields of the current search type:
+// its own fields followed by those of the records of its relations to one
+// record.
+func (svc *Service) fieldDefinitions() []Field {
+	if t := svc.entity(); t != nil {
+		return t.fields
 	}
-	return tickets, nil
+	return nil
 }
 
-func buildUserGraph(user User, organizations []Organization, tickets []Ticket) User {
-	for _, org := range organizations {
-		if user.OrganizationId == org.Id {
-			user.Organization = org
+// Field returns the definition of a field of the current search type.
+func (svc *Service) Field(name string) (Field, error) {
+	for _, f := range svc.fieldDefinitions() {
+		if f.Name == name {
+			return f, nil
 		}
 	}
+	return Field{}, svc.ValidateField(name)
+}
 
-	submittedTickets := make([]Ticket, 0)
-	assignedTickets := make([]Ticket, 0)
-	for _, ticket := range tickets {
-		if user.Id == ticket.SubmitterId {
-			submittedTickets = append(submittedTickets, ticket)
-		}
-		if user.Id == ticket.AssigneeId {
-			assignedTickets = append(assignedTickets, ticket)
-		}
+// ValidateField returns an error when field is not searchable for the
+// current search type.
+func (svc *Service) ValidateField(field string) error {
+	fields := svc.ListFields()
+	if !contains(fields, field) {
+		return fmt.Errorf("%w %q for %s, expected one of %s", ErrUnknownField, field, svc.searchType, strings.Join(fields, ", "))
 	}
-	user.SubmittedTickets = submittedTickets
-	user.AssignedTickets = assignedTickets
-	return user
+	return nil
 }
 
-func buildTicketGraph(ticket Ticket, organizations []Organization, users []User) Ticket {
-	for _, org := range organizations {
-		if ticket.OrganizationId == org.Id {
-			ticket.Organization = org
-		}
+// openFile opens the data file of a type, naming the file in any error. A file
+// that does not exist is reported with an error wrapping os.ErrNotExist.
+func (svc *Service) openFile(searchType Type, fileName string) (io.ReadCloser, error) {
+	if fileName == "" {
+		return nil, fmt.Errorf("no data file given for %s", strings.ToLower(string(searchType)))
 	}
-	for _, user := range users {
-		if ticket.AssigneeId == user.Id {
-			ticket.Assignee = user
-		}
-		if ticket.SubmitterId == user.Id {
-			ticket.Submitter = user
-		}
+	f, err := svc.fs.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s data file %s: %w", strings.ToLower(string(searchType)), fileName, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s data file %s: %w", strings.ToLower(string(searchType)), fileName, err)
 	}
-	return ticket
+	return f, nil
+}
+
+func parseError(searchType Type, fileName string, err error) error {
+	return fmt.Errorf("cannot parse %s data file %s: %v", strings.ToLower(string(searchType)), fileName, err)
 }
 
-func searchTypeToDocType(searchType Type) DocType {
-	var docType DocType
-	switch searchType {
-	case USER_SEARCH:
-		docType = USER_DOC_TYPE
-		break
-	case ORGANIZATION_SEARCH:
-		docType = ORGANIZATION_DOC_TYPE
-		break
-	case TICKET_SEARCH:
-		docType = TICKET_DOC_TYPE
-		break
+// readRaw passes the values of each record of the data file of t to fn as
+// they are decoded, along with the position of the record in the file,
+// counting from 1, and whether the file is CSV.
+func (svc *Service) readRaw(t *EntityType, fn func(n int, raw map[string]interface{}, fromCSV bool) error) error {
+	fileName := svc.files[t.Name]
+
+	f, err := svc.openFile(t.Name, fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec, err := NewFileDecoder(fileName, f)
+	if err != nil {
+		return parseError(t.Name, fileName, err)
+	}
+	_, fromCSV := dec.(*csvDecoder)
+	for n := 1; ; n++ {
+		var raw map[string]interface{}
+		ok, err := dec.Next(&raw)
+		if err != nil {
+			return parseError(t.Name, fileName, err)
+		}
+		if !ok {
+			return nil
+		}
+		if err = fn(n, raw, fromCSV); err != nil {
+			return err
+		}
 	}
-	return docType
 }
 
 func contains(slice []string, element string) bool {
@@ -1,6 +1,13 @@
 package search_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,13 +15,27 @@ import (
 	"github.com/tmicheletto/zen/internal/search"
 )
 
+// TestMain keeps the indexes built by the services of the tests, which are
+// not all closed, in a temporary directory of their own that is removed once
+// the tests have run.
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "zen-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("TMPDIR", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 type mockFileService struct {
 	mock.Mock
 }
 
-func (fs *mockFileService) ReadFile(fileName string) ([]byte, error) {
+func (fs *mockFileService) Open(fileName string) (io.ReadCloser, error) {
 	args := fs.Called(fileName)
-	return args.Get(0).([]byte), args.Error(1)
+	return ioutil.NopCloser(bytes.NewReader(args.Get(0).([]byte))), args.Error(1)
 }
 
 var usersJson = `[{
@@ -116,9 +137,9 @@ var ticketsJson = `[{
 func TestUserSearchReturnsResult(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.USER_SEARCH)
@@ -162,9 +183,9 @@ func TestUserSearchReturnsResult(t *testing.T) {
 func TestUserSearchReturnsNoResult(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.USER_SEARCH)
@@ -183,9 +204,9 @@ func TestUserSearchReturnsNoResult(t *testing.T) {
 func TestOrganizationSearchReturnsResult(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.ORGANIZATION_SEARCH)
@@ -214,9 +235,9 @@ func TestOrganizationSearchReturnsResult(t *testing.T) {
 func TestOrganizationSearchReturnsNoResult(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.ORGANIZATION_SEARCH)
@@ -235,9 +256,9 @@ func TestOrganizationSearchReturnsNoResult(t *testing.T) {
 func TestTicketSearchReturnsResult(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.TICKET_SEARCH)
@@ -272,9 +293,9 @@ func TestTicketSearchReturnsResult(t *testing.T) {
 func TestTicketSearchReturnsMultipleResults(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.TICKET_SEARCH)
@@ -290,12 +311,24 @@ func TestTicketSearchReturnsMultipleResults(t *testing.T) {
 	assert.Equal(t, 2, len(result))
 }
 
+var userFields = []string{"_id", "url", "external_id", "name", "alias", "created_at", "active", "shared", "verified", "locale", "timezone", "last_login_at", "email", "phone", "signature", "organization_id", "tags", "suspended", "role"}
+
+var organizationFields = []string{"_id", "url", "external_id", "name", "domain_names", "created_at", "details", "shared_tickets", "tags"}
+
+func prefixed(relation string, fields []string) []string {
+	result := make([]string, len(fields))
+	for i, f := range fields {
+		result[i] = relation + "." + f
+	}
+	return result
+}
+
 func TestListUserFields(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.TICKET_SEARCH)
@@ -307,15 +340,16 @@ func TestListUserFields(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	assert.Equal(t, []string{"_id", "url", "external_id", "created_at", "type", "subject", "description", "priority", "status", "tags", "has_incidents", "due_at", "via", "submitter_id", "assignee_id", "organization_id"}, result)
+	assert.Equal(t, append(append(append([]string{"_id", "url", "external_id", "created_at", "type", "subject", "description", "priority", "status", "tags", "has_incidents", "due_at", "via", "submitter_id", "assignee_id", "organization_id"},
+		prefixed("submitter", userFields)...), prefixed("assignee", userFields)...), prefixed("organization", organizationFields)...), result)
 }
 
 func TestListTicketFields(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.USER_SEARCH)
@@ -327,15 +361,15 @@ func TestListTicketFields(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	assert.Equal(t, []string{"_id", "url", "external_id", "name", "alias", "created_at", "active", "shared", "verified", "locale", "timezone", "last_login_at", "email", "phone", "signature", "organization_id", "tags", "suspended", "role"}, result)
+	assert.Equal(t, append(userFields, prefixed("organization", organizationFields)...), result)
 }
 
 func TestListOrganizationFields(t *testing.T) {
 	mfs := &mockFileService{}
 
-	mfs.On("ReadFile", "./data/users.json").Return([]byte(usersJson), nil)
-	mfs.On("ReadFile", "./data/organizations.json").Return([]byte(orgsJson), nil)
-	mfs.On("ReadFile", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
 
 	svc := search.New(mfs)
 	err := svc.Init(search.ORGANIZATION_SEARCH)
@@ -347,5 +381,699 @@ func TestListOrganizationFields(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	assert.Equal(t, []string{"_id", "url", "external_id", "name", "domain_names", "created_at", "details", "shared_tickets", "tags"}, result)
+	assert.Equal(t, organizationFields, result)
+}
+
+func TestTicketSearchById(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Search("_id", "87db32c5-76a3-4069-954c-7d59c6c21de0")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "A Problem in Morocco", result[0]["subject"])
+	assert.Equal(t, "Limozen", result[0]["organization"])
+	assert.Equal(t, "Burgess England", result[0]["assignee"])
+	assert.Equal(t, "Burgess England", result[0]["submitter"])
+}
+
+func TestUserSearchByBoolean(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.USER_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Search("suspended", "true")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+
+	result, err = svc.Search("verified", "true")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 0, len(result))
+
+	_, err = svc.Search("verified", "sometimes")
+	assert.Error(t, err)
+}
+
+func TestTicketSearchByNumber(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Search("organization_id", "1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 2, len(result))
+
+	result, err = svc.Search("organization_id", "11")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 0, len(result))
+
+	_, err = svc.Search("organization_id", "one")
+	assert.Error(t, err)
+}
+
+func TestTicketSearchByDate(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Search("created_at", "2016-07-16T12:05:12 -10:00")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "A Catastrophe in Hungary", result[0]["subject"])
+
+	result, err = svc.Search("due_at", "2016-08-19")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "A Problem in Morocco", result[0]["subject"])
+}
+
+func TestGetUser(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.USER_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	user, err := svc.Get("1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	assert.Equal(t, "Burgess England", user["name"])
+	assert.Equal(t, "Limozen", user["organization"].(map[string]interface{})["name"])
+	assert.Equal(t, 2, len(user["submitted_tickets"].([]map[string]interface{})))
+	assert.Equal(t, "A Problem in Morocco", user["assigned_tickets"].([]map[string]interface{})[1]["subject"])
+}
+
+func TestGetTicket(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	ticket, err := svc.Get("2217c7dc-7371-4401-8738-0a8a8aedc08d")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	assert.Equal(t, "A Catastrophe in Hungary", ticket["subject"])
+	assert.Equal(t, "Burgess England", ticket["assignee"].(map[string]interface{})["name"])
+	assert.Equal(t, "Burgess England", ticket["submitter"].(map[string]interface{})["name"])
+	assert.Equal(t, "Limozen", ticket["organization"].(map[string]interface{})["name"])
+}
+
+func TestGetOrganizationNotFound(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.ORGANIZATION_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	_, err = svc.Get("2")
+	assert.True(t, errors.Is(err, search.ErrNotFound))
+}
+
+func TestTicketSearchByDateRange(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.SearchRange("due_at", search.Range{Min: "2016-08-01", Max: "2016-08-06", MinInclusive: true, MaxInclusive: true})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "A Catastrophe in Hungary", result[0]["subject"])
+
+	result, err = svc.SearchRange("due_at", search.Range{Min: "2016-08-06"})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "A Problem in Morocco", result[0]["subject"])
+
+	result, err = svc.Search("created_at", "<2016-07-16T12:05:12 -10:00")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "A Problem in Morocco", result[0]["subject"])
+
+	_, err = svc.SearchRange("subject", search.Range{Min: "A"})
+	assert.Error(t, err)
+}
+
+func TestUserSearchByNumericRange(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.USER_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Search("_id", "0..1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+
+	result, err = svc.Search("_id", ">1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 0, len(result))
+}
+
+var unassignedTicketsJson = `[{
+    "_id": "6aac0369-a7e5-4417-8b50-92528ef485d3",
+    "external_id": "",
+    "created_at": "2016-06-15T12:03:56 -10:00",
+    "type": "question",
+    "subject": "A Nuisance in Seychelles",
+    "priority": "high",
+    "status": "open",
+    "submitter_id": 1,
+    "organization_id": 1,
+    "tags": [],
+    "has_incidents": false,
+    "via": "chat"
+  }]`
+
+func TestTicketSearchEmpty(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(unassignedTicketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	for _, field := range []string{"assignee_id", "tags", "description", "due_at", "external_id", "url"} {
+		result, err := svc.SearchEmpty(field)
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		assert.Equal(t, 1, len(result), field)
+	}
+
+	for _, field := range []string{"submitter_id", "subject", "has_incidents"} {
+		result, err := svc.SearchEmpty(field)
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		assert.Equal(t, 0, len(result), field)
+	}
+
+	_, err = svc.SearchEmpty("assignee")
+	assert.Error(t, err)
+}
+
+func TestTicketFindPages(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	condition := search.Condition{Field: "type", Value: "problem"}
+	first, err := svc.Find(search.Request{Query: condition, Limit: 1})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, uint64(2), first.Total)
+	assert.Equal(t, 1, len(first.Hits))
+
+	second, err := svc.Find(search.Request{Query: condition, Offset: 1, Limit: 1})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, uint64(2), second.Total)
+	assert.Equal(t, 1, second.Offset)
+	assert.Equal(t, 1, len(second.Hits))
+	assert.NotEqual(t, first.Hits[0]["_id"], second.Hits[0]["_id"])
+
+	past, err := svc.Find(search.Request{Query: condition, Offset: 2, Limit: 1})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, uint64(2), past.Total)
+	assert.Equal(t, 0, len(past.Hits))
+}
+
+func TestTicketFindSorted(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	condition := search.Condition{Field: "type", Value: "problem"}
+	tests := []struct {
+		sort     []string
+		expected []string
+	}{
+		{[]string{"due_at"}, []string{"A Catastrophe in Hungary", "A Problem in Morocco"}},
+		{[]string{"-due_at"}, []string{"A Problem in Morocco", "A Catastrophe in Hungary"}},
+		{[]string{"-subject"}, []string{"A Problem in Morocco", "A Catastrophe in Hungary"}},
+		{[]string{"priority"}, []string{"A Catastrophe in Hungary", "A Problem in Morocco"}},
+		{[]string{"organization_id", "-_id"}, []string{"A Problem in Morocco", "A Catastrophe in Hungary"}},
+		{[]string{"via", "_id"}, []string{"A Problem in Morocco", "A Catastrophe in Hungary"}},
+	}
+
+	for _, test := range tests {
+		results, err := svc.Find(search.Request{Query: condition, Sort: test.sort})
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		subjects := make([]string, len(results.Hits))
+		for i, hit := range results.Hits {
+			subjects[i] = hit["subject"].(string)
+		}
+		assert.Equal(t, test.expected, subjects, test.sort)
+	}
+
+	_, err = svc.Find(search.Request{Query: condition, Sort: []string{"assignee"}})
+	assert.Error(t, err)
+}
+
+func TestTicketSearchByRelationship(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	tests := []struct {
+		condition search.Condition
+		expected  int
+	}{
+		{search.Condition{Field: "organization.name", Value: "limozen"}, 2},
+		{search.Condition{Field: "organization._id", Value: "1"}, 2},
+		{search.Condition{Field: "submitter.role", Value: "end-user"}, 2},
+		{search.Condition{Field: "submitter.role", Value: "admin"}, 0},
+		{search.Condition{Field: "assignee.email", Value: "nealengland@flotonic.com"}, 2},
+		{search.Condition{Field: "assignee.suspended", Value: "true"}, 2},
+		{search.Condition{Field: "assignee.name", Empty: true}, 0},
+	}
+
+	for _, test := range tests {
+		results, err := svc.Find(search.Request{Query: test.condition})
+		if err != nil {
+			assert.Fail(t, err.Error(), test.condition.Field)
+			continue
+		}
+		assert.Equal(t, uint64(test.expected), results.Total, test.condition.Field)
+	}
+
+	svc = search.New(mfs)
+	err = svc.Init(search.USER_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	result, err := svc.Search("organization.details", "megacorp")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+}
+
+func TestTicketSearchEmptyRelationship(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(unassignedTicketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	for _, field := range []string{"assignee.name", "assignee._id", "assignee.suspended"} {
+		result, err := svc.SearchEmpty(field)
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		assert.Equal(t, 1, len(result), field)
+	}
+
+	result, err := svc.SearchEmpty("submitter.name")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 0, len(result))
+}
+
+func TestTicketFindNested(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "./data/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "./data/tickets.json").Return([]byte(unassignedTicketsJson), nil)
+
+	svc := search.New(mfs)
+	err := svc.Init(search.TICKET_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	results, err := svc.Find(search.Request{Query: search.Condition{Field: "status", Value: "open"}, Nested: true})
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	assert.Equal(t, 1, len(results.Hits))
+	ticket := results.Hits[0]
+	assert.Equal(t, "A Nuisance in Seychelles", ticket["subject"])
+	assert.Equal(t, json.Number("1"), ticket["submitter_id"])
+	assert.Nil(t, ticket["assignee_id"])
+	assert.Equal(t, []interface{}{}, ticket["tags"])
+	assert.Equal(t, "Burgess England", ticket["submitter"].(map[string]interface{})["name"])
+	assert.Equal(t, json.Number("1"), ticket["organization"].(map[string]interface{})["_id"])
+	assert.Nil(t, ticket["assignee"])
+}
+
+func TestInitReadsGivenFiles(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "/export/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "/export/organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "/archive/tickets-2016.json").Return([]byte(ticketsJson), nil)
+
+	files := search.DefaultSchema.DataFiles("/export")
+	files[search.TICKET_SEARCH] = "/archive/tickets-2016.json"
+	svc := search.NewWithFiles(mfs, files)
+	err := svc.Init(search.USER_SEARCH)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	result, err := svc.Search("_id", "1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, 1, len(result))
+	mfs.AssertExpectations(t)
+}
+
+func TestInitReportsMissingFile(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "/export/users.json").Return([]byte(usersJson), nil)
+	mfs.On("Open", "/export/organizations.json").Return([]byte(nil), &os.PathError{Op: "open", Path: "/export/organizations.json", Err: os.ErrNotExist})
+	mfs.On("Open", "/export/tickets.json").Return([]byte(ticketsJson), nil)
+
+	svc := search.NewWithFiles(mfs, search.DefaultSchema.DataFiles("/export"))
+	err := svc.Init(search.USER_SEARCH)
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Contains(t, err.Error(), "organizations data file /export/organizations.json: file does not exist")
+}
+
+func TestRelationsAreInSchemaOrder(t *testing.T) {
+	svc := newValidateService(usersJson, orgsJson, ticketsJson)
+	if err := svc.Init(search.USER_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	assert.Equal(t, []string{"organization", "submitted_tickets", "assigned_tickets"}, svc.RelationNames())
+	related := svc.RelatedFields()
+	assert.Equal(t, organizationFields, related["organization"])
+	assert.Equal(t, "_id", related["submitted_tickets"][0])
+	assert.Equal(t, related["submitted_tickets"], related["assigned_tickets"])
+}
+
+func TestListFieldsNeedsNoData(t *testing.T) {
+	// Opening any file fails the test, as the mock expects none to be.
+	svc := search.New(&mockFileService{})
+	if err := svc.SetSearchType(search.ORGANIZATION_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, organizationFields, svc.ListFields())
+	assert.Error(t, svc.SetSearchType("Agents"))
+}
+
+func TestLoadFieldsKeepsTheDeclaredFieldsOnError(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/organizations.json").Return([]byte(nil), &os.PathError{Op: "open", Path: "./data/organizations.json", Err: os.ErrNotExist})
+
+	svc := search.New(mfs)
+	err := svc.LoadFields(search.ORGANIZATION_SEARCH)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Equal(t, organizationFields, svc.ListFields())
+}
+
+func TestLoadFieldsReadsOnlyTheFilesOfTheFields(t *testing.T) {
+	mfs := &mockFileService{}
+
+	mfs.On("Open", "./data/organizations.json").Return([]byte(`[{"_id": 1, "name": "Limozen", "region": "APAC"}]`), nil)
+
+	svc := search.New(mfs)
+	if err := svc.LoadFields(search.ORGANIZATION_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, append(organizationFields, "region"), svc.ListFields())
+	mfs.AssertExpectations(t)
+
+	assert.Error(t, svc.LoadFields("Agents"))
+}
+
+var notesSchema = agentsSchema + `  - name: Notes
+    doc_type: note
+    file: notes.json
+    fields:
+      - {name: _id, type: numeric}
+      - {name: text}
+`
+
+func TestInitIndexesOnlyTheSearchedType(t *testing.T) {
+	schema, err := search.LoadSchema(strings.NewReader(notesSchema))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	// The notes are not read when searching agents, which do not relate to
+	// them.
+	mfs := &mockFileService{}
+	mfs.On("Open", "data/agents.json").Return([]byte(agentsJson), nil)
+	mfs.On("Open", "data/groups.json").Return([]byte(groupsJson), nil)
+	svc := search.NewWithSchema(mfs, schema, schema.DataFiles("data"))
+
+	if err = svc.Init(search.Type("Agents")); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	result, err := svc.Search("group.name", "support")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, "Ada Lovelace", result[0]["name"])
+	assert.Equal(t, "Support Team", result[0]["group"])
+
+	// The groups are indexed to be shown with the agents, but not searched.
+	all, err := svc.SearchAll(search.Request{Query: search.Text{Value: "support"}})
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 3, len(all.Groups))
+	for _, group := range all.Groups {
+		if group.Type != "Agents" {
+			assert.Equal(t, uint64(0), group.Total, group.Type)
+		}
+	}
+}
+
+func TestInitReadsEachDataFileOnce(t *testing.T) {
+	mfs := &mockFileService{}
+	mfs.On("Open", "users.json").Return([]byte(`[{"_id": 1, "name": "Burgess England", "organization_id": 1, "team": "Billing"}]`), nil)
+	mfs.On("Open", "organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "tickets.json").Return([]byte(ticketsJson), nil)
+	svc := search.NewWithFiles(mfs, validateFiles)
+	if err := svc.Init(search.TICKET_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer svc.Close()
+
+	opened := make(map[string]int)
+	for _, call := range mfs.Calls {
+		opened[call.Arguments.String(0)]++
+	}
+	assert.Equal(t, map[string]int{"users.json": 1, "organizations.json": 1, "tickets.json": 1}, opened)
+
+	// The field inferred from the users is embedded in the tickets indexed
+	// after them.
+	result, err := svc.Search("submitter.team", "billing")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "Burgess England", result[0]["submitter"])
+}
+
+var employeesSchema = `types:
+  - name: Employees
+    doc_type: employee
+    file: employees.json
+    fields:
+      - {name: _id, type: numeric}
+      - {name: name}
+      - {name: manager_id, type: numeric}
+    relations:
+      - {name: manager, type: Employees, field: manager_id, display: name}
+`
+
+func TestInitLinksRecordsIndexedBeforeTheirRelatedRecords(t *testing.T) {
+	schema, err := search.LoadSchema(strings.NewReader(employeesSchema))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mfs := &mockFileService{}
+	mfs.On("Open", "data/employees.json").Return([]byte(`[{"_id": 1, "name": "Ada Lovelace", "manager_id": 2}, {"_id": 2, "name": "Charles Babbage"}]`), nil)
+	svc := search.NewWithSchema(mfs, schema, schema.DataFiles("data"))
+	if err := svc.Init("Employees"); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer svc.Close()
+
+	result, err := svc.Search("manager.name", "babbage")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if assert.Equal(t, 1, len(result)) {
+		assert.Equal(t, "Ada Lovelace", result[0]["name"])
+		assert.Equal(t, "Charles Babbage", result[0]["manager"])
+	}
+}
+
+func TestInitReportsUnknownType(t *testing.T) {
+	svc := search.New(&mockFileService{})
+	assert.Error(t, svc.Init("Agents"))
+}
+
+func TestDuplicateIdsRelateToTheFirstRecord(t *testing.T) {
+	users := `[{"_id": 1, "name": "Burgess England", "organization_id": 1}, {"_id": 1, "name": "Duplicate Second"}]`
+	svc := newValidateService(users, orgsJson, ticketsJson)
+	if err := svc.Init(search.TICKET_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	result, err := svc.Search("submitter_id", "1")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, 2, len(result))
+	for _, ticket := range result {
+		assert.Equal(t, "Burgess England", ticket["submitter"])
+	}
+
+	if err := svc.Init(search.USER_SEARCH); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	user, err := svc.Get("1")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, "Burgess England", user["name"])
 }
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/mapping"
+	bsearch "github.com/blevesearch/bleve/search"
+)
+
+// SORT_FIELD_PREFIX prefixes the index fields holding a sortable copy of a
+// text field. Text fields are indexed as analyzed tokens, so they are sorted
+// on a lower cased copy of the whole value instead.
+const SORT_FIELD_PREFIX = "_sort."
+
+const SORT_ANALYZER = "sort"
+
+// SCORE_SORT_KEY sorts results by relevance.
+const SCORE_SORT_KEY = "_score"
+
+// ID_FIELD_NAME is the primary key of every record and breaks ties in the
+// sort order.
+const ID_FIELD_NAME = "_id"
+
+func addSortAnalyzer(indexMapping *mapping.IndexMappingImpl) error {
+	return indexMapping.AddCustomAnalyzer(SORT_ANALYZER, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []interface{}{lowercase.Name},
+	})
+}
+
+func buildSortFieldMapping(field Field) *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Name = SORT_FIELD_PREFIX + field.Name
+	fm.Analyzer = SORT_ANALYZER
+	fm.Store = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	return fm
+}
+
+// ParseSortKey splits a sort key such as "-due_at" or "priority" into the
+// field name and whether the order is descending. A leading "+" is accepted
+// for ascending order.
+func ParseSortKey(key string) (string, bool) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "-") {
+		return strings.TrimPrefix(key, "-"), true
+	}
+	return strings.TrimPrefix(key, "+"), false
+}
+
+// buildSortOrder translates sort keys into the index sort order. Results are
+// sorted by relevance when no keys are given, and ties are always broken on
+// _id so that paging through results is deterministic.
+func (svc *Service) buildSortOrder(keys []string) (bsearch.SortOrder, error) {
+	if len(keys) == 0 {
+		keys = []string{"-" + SCORE_SORT_KEY}
+	}
+
+	order := make(bsearch.SortOrder, 0, len(keys)+2)
+	hasID := false
+	for _, key := range keys {
+		name, desc := ParseSortKey(key)
+		if name == SCORE_SORT_KEY {
+			order = append(order, &bsearch.SortScore{Desc: desc})
+			continue
+		}
+
+		field, err := svc.Field(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sort key %q: %v", key, err)
+		}
+		order = append(order, buildSortField(field, desc))
+		hasID = hasID || field.Name == ID_FIELD_NAME
+	}
+
+	if !hasID {
+		if field, err := svc.Field(ID_FIELD_NAME); err == nil {
+			order = append(order, buildSortField(field, false))
+		}
+	}
+	// Duplicated primary keys are still ordered consistently.
+	order = append(order, &bsearch.SortDocID{})
+	return order, nil
+}
+
+func buildSortField(field Field, desc bool) *bsearch.SortField {
+	sortField := &bsearch.SortField{
+		Field:   field.Name,
+		Desc:    desc,
+		Type:    bsearch.SortFieldAsString,
+		Missing: bsearch.SortFieldMissingLast,
+	}
+	switch field.Type {
+	case NUMERIC_FIELD:
+		sortField.Type = bsearch.SortFieldAsNumber
+	case DATETIME_FIELD:
+		sortField.Type = bsearch.SortFieldAsDate
+	case TEXT_FIELD:
+		sortField.Field = SORT_FIELD_PREFIX + field.Name
+	}
+	return sortField
+}
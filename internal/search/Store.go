@@ -0,0 +1,269 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/mapping"
+	bsearch "github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// SOURCE_FIELD_NAME is the stored, unindexed field of a document holding the
+// record it was built from, which search results are built from in turn.
+const SOURCE_FIELD_NAME = "_source"
+
+// LINK_CACHE_SIZE is the number of lookups of related records a linker keeps
+// the results of.
+const LINK_CACHE_SIZE = 10000
+
+// storedRecord is a record as it is kept in the index: the values read from
+// its data file, which are converted to the types of the fields of its type
+// when it is read back, so that the fields inferred after it was indexed are
+// converted too.
+type storedRecord struct {
+	Type   Type                   `json:"type"`
+	Values map[string]interface{} `json:"values"`
+	CSV    bool                   `json:"csv,omitempty"`
+}
+
+// documentID returns the id of the document of the record of t at position n
+// of its data file. The ids of the records of a type sort in the order of the
+// file, so that the first record wins when a primary key is duplicated.
+func documentID(t *EntityType, n int) string {
+	return fmt.Sprintf("%s:%09d", t.DocType, n)
+}
+
+func buildSourceFieldMapping() *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Index = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	fm.DocValues = false
+	return fm
+}
+
+// decodeStored decodes a record kept in the index.
+func decodeStored(source []byte) (storedRecord, error) {
+	dec := json.NewDecoder(bytes.NewReader(source))
+	dec.UseNumber()
+	var sr storedRecord
+	err := dec.Decode(&sr)
+	return sr, err
+}
+
+// readStored converts a record kept in the index to the fields of its type.
+func (svc *Service) readStored(source []byte) (*record, error) {
+	sr, err := decodeStored(source)
+	if err != nil {
+		return nil, err
+	}
+	t := svc.schema.Type(sr.Type)
+	if t == nil {
+		return nil, fmt.Errorf("unknown type %s", sr.Type)
+	}
+	return newRecord(t, sr.Values, sr.CSV)
+}
+
+// readSource reads back the record kept in the document with id.
+func (svc *Service) readSource(id string) (storedRecord, error) {
+	doc, err := svc.index.Document(id)
+	if err != nil {
+		return storedRecord{}, err
+	}
+	if doc != nil {
+		for _, f := range doc.Fields {
+			if f.Name() == SOURCE_FIELD_NAME {
+				return decodeStored(f.Value())
+			}
+		}
+	}
+	return storedRecord{}, fmt.Errorf("no record stored for document %s", id)
+}
+
+// readHit reads back the record of a hit, which must have been searched for
+// with SOURCE_FIELD_NAME among its fields.
+func (svc *Service) readHit(hit *bsearch.DocumentMatch) (*record, error) {
+	source, ok := hit.Fields[SOURCE_FIELD_NAME].(string)
+	if !ok {
+		return nil, fmt.Errorf("no record stored for document %s", hit.ID)
+	}
+	return svc.readStored([]byte(source))
+}
+
+// linker links records to the records they relate to, looking them up in the
+// index. The records looked up are cached, as the same few are often related
+// to many records, e.g. the organization of a ticket.
+type linker struct {
+	svc   *Service
+	cache map[string][]*record
+}
+
+func (svc *Service) newLinker() *linker {
+	return &linker{svc: svc, cache: make(map[string][]*record)}
+}
+
+// link links a record to the record of each of its relations to one record
+// that exists, and to the records of its relations to many too when toMany is
+// set. When a primary key is duplicated the first record with it is linked,
+// as it is the one looked up.
+func (l *linker) link(r *record, toMany bool) error {
+	r.related = make(map[string]*record)
+	r.lists = make(map[string][]*record)
+	for _, rel := range r.entity.Relations {
+		if !rel.toOne() {
+			if !toMany {
+				continue
+			}
+			related, err := l.lookup(rel.Type, rel.Inverse, r.id(), 0)
+			if err != nil {
+				return err
+			}
+			r.lists[rel.Name] = related
+			continue
+		}
+		related, err := l.lookup(rel.Type, ID_FIELD_NAME, idString(r.values[rel.Field]), 1)
+		if err != nil {
+			return err
+		}
+		if len(related) > 0 {
+			r.related[rel.Name] = related[0]
+		}
+	}
+	return nil
+}
+
+// hit reads back the record of a hit and links it.
+func (l *linker) hit(hit *bsearch.DocumentMatch, toMany bool) (*record, error) {
+	r, err := l.svc.readHit(hit)
+	if err != nil {
+		return nil, err
+	}
+	return r, l.link(r, toMany)
+}
+
+// lookup returns the records of searchType whose field holds key, in the
+// order of their data file, up to limit records unless limit is zero or less.
+func (l *linker) lookup(searchType Type, field string, key string, limit int) ([]*record, error) {
+	if key == "" {
+		return nil, nil
+	}
+	cacheKey := fmt.Sprintf("%s\x00%s\x00%s\x00%d", searchType, field, key, limit)
+	if records, ok := l.cache[cacheKey]; ok {
+		return records, nil
+	}
+
+	records, err := l.svc.findByKey(searchType, field, key, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(l.cache) >= LINK_CACHE_SIZE {
+		l.cache = make(map[string][]*record)
+	}
+	l.cache[cacheKey] = records
+	return records, nil
+}
+
+// findByKey searches the index for the records of searchType whose field
+// holds key as a whole, in the order of their data file.
+func (svc *Service) findByKey(searchType Type, field string, key string, limit int) ([]*record, error) {
+	t := svc.schema.Type(searchType)
+	if t == nil || t.field(field) == nil {
+		return nil, nil
+	}
+	keyQuery, ok := buildKeyQuery(*t.field(field), key)
+	if !ok {
+		return nil, nil
+	}
+	docTypeQuery := bleve.NewTermQuery(string(t.DocType))
+	docTypeQuery.SetField(DOC_TYPE_FIELD_NAME)
+
+	if limit <= 0 {
+		count, err := svc.index.DocCount()
+		if err != nil {
+			return nil, err
+		}
+		limit = int(count)
+	}
+	searchRequest := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(docTypeQuery, keyQuery), limit, 0, false)
+	searchRequest.SortByCustom(bsearch.SortOrder{&bsearch.SortDocID{}})
+	searchRequest.Fields = []string{SOURCE_FIELD_NAME}
+	searchResult, err := svc.index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*record, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		r, err := svc.readHit(hit)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// buildKeyQuery returns a query matching the documents whose field holds key,
+// the _id of a record, as a whole. No query is returned when the field cannot
+// hold key.
+func buildKeyQuery(field Field, key string) (query.Query, bool) {
+	switch field.Type {
+	case TEXT_FIELD:
+		q := bleve.NewTermQuery(key)
+		q.SetField(EXACT_FIELD_PREFIX + field.Name)
+		return q, true
+	case KEYWORD_FIELD:
+		q := bleve.NewTermQuery(key)
+		q.SetField(field.Name)
+		return q, true
+	}
+	q, err := buildFieldQuery(field, key)
+	return q, err == nil
+}
+
+// indexWriter adds the records of a data file to the index in batches of
+// INDEX_BATCH_SIZE, linked to the records of their relations to one record
+// that have been indexed.
+type indexWriter struct {
+	svc   *Service
+	batch *bleve.Batch
+	links *linker
+}
+
+func (svc *Service) newIndexWriter() *indexWriter {
+	return &indexWriter{svc: svc, batch: svc.index.NewBatch(), links: svc.newLinker()}
+}
+
+// add indexes sr, the record at position n of its data file.
+func (w *indexWriter) add(n int, sr storedRecord) error {
+	t := w.svc.schema.Type(sr.Type)
+	r, err := newRecord(t, sr.Values, sr.CSV)
+	if err != nil {
+		return parseError(t.Name, w.svc.files[t.Name], fmt.Errorf("record %d, %v", n, err))
+	}
+	if err := w.links.link(r, false); err != nil {
+		return err
+	}
+	source, err := json.Marshal(sr)
+	if err != nil {
+		return err
+	}
+	doc := buildDocument(w.svc.schema, r)
+	doc[SOURCE_FIELD_NAME] = string(source)
+	if err := w.batch.Index(documentID(t, n), doc); err != nil {
+		return err
+	}
+	if w.batch.Size() < INDEX_BATCH_SIZE {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush indexes the records added since the last batch.
+func (w *indexWriter) flush() error {
+	err := w.svc.index.Batch(w.batch)
+	w.batch.Reset()
+	return err
+}
@@ -0,0 +1,239 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProblemKind classifies the problems found by Validate.
+type ProblemKind string
+
+const (
+	DANGLING_REFERENCE ProblemKind = "dangling reference"
+	DUPLICATE_ID       ProblemKind = "duplicate id"
+	INVALID_TIMESTAMP  ProblemKind = "invalid timestamp"
+	UNKNOWN_VALUE      ProblemKind = "unknown value"
+	SCHEMA_MISMATCH    ProblemKind = "schema mismatch"
+	UNDECLARED_FIELD   ProblemKind = "undeclared field"
+)
+
+// Informational reports whether problems of kind k are only reported, rather
+// than failing validation. Fields the schema does not declare are searched
+// with the types inferred from their values, so they are informational.
+func (k ProblemKind) Informational() bool {
+	return k == UNDECLARED_FIELD
+}
+
+// Problem is a problem found in a record of a data file. Record is the
+// position of the record in the file, counting from 1, and ID its _id when
+// it has one.
+type Problem struct {
+	Type    Type
+	File    string
+	Record  int
+	ID      string
+	Field   string
+	Kind    ProblemKind
+	Message string
+}
+
+// ValidationResult lists the problems found in the data files, in the order
+// they were found, along with the types in the order they were validated and
+// the number of records of each.
+type ValidationResult struct {
+	Types    []Type
+	Records  map[Type]int
+	Problems []Problem
+}
+
+// Failed reports whether any of the problems found fails validation, being
+// more than informational.
+func (r *ValidationResult) Failed() bool {
+	for _, p := range r.Problems {
+		if !p.Kind.Informational() {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the data files without indexing them, reporting records
+// whose _id is missing or used by another record of the same type, whose
+// references point to records that do not exist, whose timestamps cannot be
+// parsed, whose fields have values that are not allowed or not of the type
+// expected, and fields the schema does not declare, which are informational.
+// An error is returned only when a data file cannot be read or parsed. Types
+// are validated after the types their records refer to where possible.
+func (svc *Service) Validate() (*ValidationResult, error) {
+	result := &ValidationResult{
+		Types:    make([]Type, 0, len(svc.schema.Types)),
+		Records:  make(map[Type]int),
+		Problems: make([]Problem, 0),
+	}
+	ids := make(map[Type]map[string]int)
+	// References to records of types that are yet to be validated are
+	// checked once all of them are.
+	pending := make([]pendingReference, 0)
+	for _, t := range svc.schema.loadOrder() {
+		result.Types = append(result.Types, t.Name)
+		ids[t.Name] = make(map[string]int)
+		if err := svc.validateFile(t, ids, result, &pending); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range pending {
+		ref.check(ids)
+	}
+	return result, nil
+}
+
+// pendingReference is a reference to the record of another type, by its _id,
+// to be checked by v.
+type pendingReference struct {
+	v     recordValidator
+	field string
+	id    string
+	to    *EntityType
+}
+
+func (ref pendingReference) check(ids map[Type]map[string]int) {
+	if _, ok := ids[ref.to.Name][ref.id]; !ok {
+		ref.v.report(ref.field, DANGLING_REFERENCE, fmt.Sprintf("no %s with _id %s", ref.to.DocType, ref.id))
+	}
+}
+
+func (svc *Service) validateFile(t *EntityType, ids map[Type]map[string]int, result *ValidationResult, pending *[]pendingReference) error {
+	fileName := svc.files[t.Name]
+	f, err := svc.openFile(t.Name, fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec, err := NewFileDecoder(fileName, f)
+	if err != nil {
+		return parseError(t.Name, fileName, err)
+	}
+	_, fromCSV := dec.(*csvDecoder)
+
+	for n := 1; ; n++ {
+		var record map[string]interface{}
+		ok, err := dec.Next(&record)
+		if err != nil {
+			return parseError(t.Name, fileName, err)
+		}
+		if !ok {
+			return nil
+		}
+		result.Records[t.Name]++
+
+		v := recordValidator{searchType: t.Name, fileName: fileName, n: n, result: result}
+		v.id = idString(record["_id"])
+		if v.id == "" {
+			v.report("_id", SCHEMA_MISMATCH, "missing _id")
+		} else if first, ok := ids[t.Name][v.id]; ok {
+			v.report("_id", DUPLICATE_ID, fmt.Sprintf("_id %s is also used by record %d", v.id, first))
+		} else {
+			ids[t.Name][v.id] = n
+		}
+
+		names := make([]string, 0, len(record))
+		for name := range record {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := t.field(name)
+			if field == nil || field.Inferred {
+				v.reportUndeclared(t, name, record[name])
+				continue
+			}
+			v.checkValue(*field, record[name], fromCSV)
+		}
+
+		for _, rel := range t.Relations {
+			if !rel.toOne() {
+				continue
+			}
+			id := idString(record[rel.Field])
+			if id == "" {
+				continue
+			}
+			ref := pendingReference{v: v, field: rel.Field, id: id, to: svc.schema.Type(rel.Type)}
+			if _, validated := ids[rel.Type]; validated {
+				ref.check(ids)
+			} else {
+				*pending = append(*pending, ref)
+			}
+		}
+	}
+}
+
+// recordValidator reports the problems of a record.
+type recordValidator struct {
+	searchType Type
+	fileName   string
+	n          int
+	id         string
+	result     *ValidationResult
+}
+
+func (v *recordValidator) report(field string, kind ProblemKind, message string) {
+	v.result.Problems = append(v.result.Problems, Problem{
+		Type:    v.searchType,
+		File:    v.fileName,
+		Record:  v.n,
+		ID:      v.id,
+		Field:   field,
+		Kind:    kind,
+		Message: message,
+	})
+}
+
+// reportUndeclared reports a field that the schema does not declare, noting
+// whether it is searched with an inferred type or left out of the index.
+func (v *recordValidator) reportUndeclared(t *EntityType, name string, value interface{}) {
+	if _, isObject := value.(map[string]interface{}); isObject || !inferable(t, name) {
+		v.report(name, UNDECLARED_FIELD, "left out of the index, it is not searched")
+		return
+	}
+	v.report(name, UNDECLARED_FIELD, "searched with a type inferred from its values")
+}
+
+// checkValue reports a value that does not suit its field. Values of CSV files
+// are strings, which are checked as they would be converted when loaded.
+func (v *recordValidator) checkValue(field Field, value interface{}, fromCSV bool) {
+	converted, err := convertValue(field, value, fromCSV)
+	if err != nil {
+		v.report(field.Name, SCHEMA_MISMATCH, err.Error())
+		return
+	}
+	values := []interface{}{converted}
+	if field.Array && converted != nil {
+		values = converted.([]interface{})
+	}
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			continue
+		}
+		if field.Type == DATETIME_FIELD && !isDateTime(s) {
+			v.report(field.Name, INVALID_TIMESTAMP, fmt.Sprintf("cannot parse %q, expected a timestamp like %q", s, DATETIME_LAYOUT))
+		}
+		if len(field.Values) > 0 && !contains(field.Values, s) {
+			v.report(field.Name, UNKNOWN_VALUE, fmt.Sprintf("%q, expected one of %s", s, strings.Join(field.Values, ", ")))
+		}
+	}
+}
+
+// isDateTime reports whether s is a timestamp that can be indexed.
+func isDateTime(s string) bool {
+	for _, layout := range datetimeLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package search_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmicheletto/zen/internal/search"
+)
+
+var validateFiles = search.Files{
+	search.USER_SEARCH:         "users.json",
+	search.ORGANIZATION_SEARCH: "organizations.json",
+	search.TICKET_SEARCH:       "tickets.json",
+}
+
+func newValidateService(users, orgs, tickets string) *search.Service {
+	mfs := &mockFileService{}
+	mfs.On("Open", "users.json").Return([]byte(users), nil)
+	mfs.On("Open", "organizations.json").Return([]byte(orgs), nil)
+	mfs.On("Open", "tickets.json").Return([]byte(tickets), nil)
+	return search.NewWithFiles(mfs, validateFiles)
+}
+
+func TestValidateFindsNoProblems(t *testing.T) {
+	svc := newValidateService(usersJson, orgsJson, ticketsJson)
+
+	result, err := svc.Validate()
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, map[search.Type]int{
+		search.USER_SEARCH:         1,
+		search.ORGANIZATION_SEARCH: 1,
+		search.TICKET_SEARCH:       2,
+	}, result.Records)
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	users := `[
+		{"_id": 1, "name": "Burgess England", "organization_id": 1, "role": "end-user"},
+		{"_id": 2, "name": "Nobody", "organization_id": 99, "role": "owner", "verified": "yes"},
+		{"_id": 1, "name": "Burgess Again", "nickname": "Burgess"}
+	]`
+	tickets := `[
+		{"_id": "a", "submitter_id": 1, "assignee_id": 3, "created_at": "last tuesday", "status": "open"},
+		{"subject": "No id", "priority": "whenever", "tags": "Ohio"}
+	]`
+	svc := newValidateService(users, orgsJson, tickets)
+
+	result, err := svc.Validate()
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	type problem struct {
+		Type   search.Type
+		Record int
+		ID     string
+		Field  string
+		Kind   search.ProblemKind
+	}
+	problems := make([]problem, len(result.Problems))
+	for i, p := range result.Problems {
+		assert.NotEmpty(t, p.Message)
+		problems[i] = problem{p.Type, p.Record, p.ID, p.Field, p.Kind}
+	}
+	assert.Equal(t, []problem{
+		{search.USER_SEARCH, 2, "2", "role", search.UNKNOWN_VALUE},
+		{search.USER_SEARCH, 2, "2", "verified", search.SCHEMA_MISMATCH},
+		{search.USER_SEARCH, 2, "2", "organization_id", search.DANGLING_REFERENCE},
+		{search.USER_SEARCH, 3, "1", "_id", search.DUPLICATE_ID},
+		{search.USER_SEARCH, 3, "1", "nickname", search.UNDECLARED_FIELD},
+		{search.TICKET_SEARCH, 1, "a", "created_at", search.INVALID_TIMESTAMP},
+		{search.TICKET_SEARCH, 1, "a", "assignee_id", search.DANGLING_REFERENCE},
+		{search.TICKET_SEARCH, 2, "", "_id", search.SCHEMA_MISMATCH},
+		{search.TICKET_SEARCH, 2, "", "priority", search.UNKNOWN_VALUE},
+		{search.TICKET_SEARCH, 2, "", "tags", search.SCHEMA_MISMATCH},
+	}, problems)
+	assert.Equal(t, "users.json", result.Problems[0].File)
+	assert.Equal(t, `"owner", expected one of end-user, agent, admin`, result.Problems[0].Message)
+}
+
+func TestValidateOnlyNotesUndeclaredFields(t *testing.T) {
+	svc := newValidateService(customUsersJson, orgsJson, ticketsJson)
+
+	result, err := svc.Validate()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.NotEmpty(t, result.Problems)
+	for _, p := range result.Problems {
+		assert.Equal(t, search.UNDECLARED_FIELD, p.Kind, p.Field)
+	}
+	assert.False(t, result.Failed())
+}
+
+func TestValidateReadsCSVValuesAsLoaded(t *testing.T) {
+	mfs := &mockFileService{}
+	mfs.On("Open", "users.csv").Return([]byte("_id,name,active,organization_id,tags\n1,Burgess England,true,1,Riceville;Ribera\n2,Nobody,maybe,x,\n"), nil)
+	mfs.On("Open", "organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "tickets.json").Return([]byte("[]"), nil)
+	svc := search.NewWithFiles(mfs, search.Files{
+		search.USER_SEARCH:         "users.csv",
+		search.ORGANIZATION_SEARCH: "organizations.json",
+		search.TICKET_SEARCH:       "tickets.json",
+	})
+
+	result, err := svc.Validate()
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	problems := make([]string, len(result.Problems))
+	for i, p := range result.Problems {
+		assert.Equal(t, 2, p.Record)
+		problems[i] = p.Field + ": " + string(p.Kind)
+	}
+	assert.Equal(t, []string{
+		"active: schema mismatch",
+		"organization_id: schema mismatch",
+		"organization_id: dangling reference",
+	}, problems)
+}
+
+func TestValidateReportsUnreadableFile(t *testing.T) {
+	mfs := &mockFileService{}
+	mfs.On("Open", "organizations.json").Return([]byte(orgsJson), nil)
+	mfs.On("Open", "users.json").Return([]byte(""), os.ErrNotExist)
+	svc := search.NewWithFiles(mfs, validateFiles)
+
+	_, err := svc.Validate()
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}